@@ -9,6 +9,7 @@ import (
 	"time"
 	"ws/app/contract"
 	"ws/app/databases"
+	"ws/app/models"
 )
 
 const (
@@ -16,6 +17,8 @@ const (
 	adminChatUserKey = "admin:%d:chat-user"
 	// 客服 => {uid: lastTime} hashes
 	adminUserLastChatKey = "admin:%d:chat-user:last-time"
+	// {value: adminId, score: 最后活跃时间} sorted sets
+	adminActiveTimeKey = "admin:active-time"
 )
 
 var (
@@ -101,6 +104,20 @@ func (adminService *adminService) UpdateLastChatTime(adminId int64, uid int64) e
 	return cmd.Err()
 }
 
+// UpdateActiveTime 更新客服最后活跃时间
+func (adminService *adminService) UpdateActiveTime(adminId int64) {
+	ctx := context.Background()
+	m := &redis.Z{Member: adminId, Score: float64(time.Now().Unix())}
+	databases.Redis.ZAdd(ctx, adminActiveTimeKey, m)
+}
+
+// GetActiveTime 获取客服最后活跃时间
+func (adminService *adminService) GetActiveTime(adminId int64) int64 {
+	ctx := context.Background()
+	cmd := databases.Redis.ZScore(ctx, adminActiveTimeKey, strconv.FormatInt(adminId, 10))
+	return int64(cmd.Val())
+}
+
 // GetActiveCount 获取有效的用户数量
 func (adminService *adminService) GetActiveCount(adminId int64) int {
 	ctx := context.Background()
@@ -111,6 +128,28 @@ func (adminService *adminService) GetActiveCount(adminId int64) int {
 	return len(cmd.Val())
 }
 
+// GetMaxChats 客服的最大同时接待人数，未单独设置时使用分组的默认设置，0为不限制
+func (adminService *adminService) GetMaxChats(admin *models.Admin) int {
+	max := admin.GetSetting().MaxChats
+	if max > 0 {
+		return max
+	}
+	return SettingService.GetMaxChats(admin.GetGroupId())
+}
+
+// CheckCapacity 检查客服是否还能接入新用户
+func (adminService *adminService) CheckCapacity(admin *models.Admin) error {
+	max := adminService.GetMaxChats(admin)
+	if max <= 0 {
+		return nil
+	}
+	count := adminService.GetActiveCount(admin.GetPrimaryKey())
+	if count >= max {
+		return fmt.Errorf("%s的接待人数已达上限(%d/%d)", admin.GetChatName(), count, max)
+	}
+	return nil
+}
+
 // UpdateLimitTime 更新有效期
 func (adminService *adminService) UpdateLimitTime(adminId int64, uid int64, duration int64) error {
 	if !adminService.IsUserExist(adminId, uid) {
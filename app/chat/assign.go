@@ -0,0 +1,84 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"ws/app/databases"
+)
+
+const (
+	AssignRoundRobin  = "round-robin"  // 轮流分配
+	AssignLeastActive = "least-active" // 分配给当前接待用户最少的客服
+)
+
+const (
+	// 轮流分配的计数器
+	assignCounterKey = "assign:%d:counter"
+	// 用户接入锁，防止同一用户被多个server或客服同时接入
+	assignUserLockKey = "assign:user:%d:lock"
+	assignLockTime    = 10 * time.Second
+)
+
+var AssignService = &assignService{}
+
+type assignService struct {
+}
+
+// Lock 获取用户的接入锁，获取失败说明用户正在被接入
+func (assignService *assignService) Lock(uid int64) bool {
+	ctx := context.Background()
+	cmd := databases.Redis.SetNX(ctx, fmt.Sprintf(assignUserLockKey, uid), 1, assignLockTime)
+	return cmd.Val()
+}
+
+// Unlock 释放用户的接入锁
+func (assignService *assignService) Unlock(uid int64) {
+	ctx := context.Background()
+	databases.Redis.Del(ctx, fmt.Sprintf(assignUserLockKey, uid))
+}
+
+// GetWaitingUsers 按加入时间顺序获取待人工接入的用户
+func (assignService *assignService) GetWaitingUsers(gid int64) []int64 {
+	ctx := context.Background()
+	cmd := databases.Redis.ZRange(ctx, ManualService.getManualKey(gid), 0, -1)
+	uids := make([]int64, 0, len(cmd.Val()))
+	for _, uidStr := range cmd.Val() {
+		id, err := strconv.ParseInt(uidStr, 10, 64)
+		if err == nil {
+			uids = append(uids, id)
+		}
+	}
+	return uids
+}
+
+// SelectAdmin 根据分组设置的分配策略从候选客服中选择一个，没有候选客服返回0
+func (assignService *assignService) SelectAdmin(gid int64, adminIds []int64) int64 {
+	if len(adminIds) == 0 {
+		return 0
+	}
+	ids := make([]int64, len(adminIds))
+	copy(ids, adminIds)
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	switch SettingService.GetAssignStrategy(gid) {
+	case AssignLeastActive:
+		selected := ids[0]
+		min := AdminService.GetActiveCount(selected)
+		for _, id := range ids[1:] {
+			count := AdminService.GetActiveCount(id)
+			if count < min {
+				selected = id
+				min = count
+			}
+		}
+		return selected
+	default:
+		ctx := context.Background()
+		n := databases.Redis.Incr(ctx, fmt.Sprintf(assignCounterKey, gid)).Val()
+		return ids[n%int64(len(ids))]
+	}
+}
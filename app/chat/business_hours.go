@@ -0,0 +1,133 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"ws/app/databases"
+	"ws/app/models"
+	"ws/app/repositories"
+)
+
+const (
+	// 非营业时间提示标记，有效期内不重复提示
+	closedNoticeKey = "user:%d:closed-notice"
+	// 非营业时间提示的间隔
+	closedNoticeDuration = time.Hour
+	// 营业时间在内存中的缓存时长，修改后其他实例最多延迟这么久生效
+	scheduleCacheDuration = time.Minute
+)
+
+var BusinessHoursService = &businessHoursService{
+	schedules: make(map[int64]*daySchedule),
+}
+
+type businessHoursService struct {
+	mutex     sync.Mutex
+	schedules map[int64]*daySchedule
+}
+
+// 分组某一天的营业时间段
+type daySchedule struct {
+	location *time.Location
+	date     string
+	allDay   bool
+	hours    []*models.BusinessHour
+	expireAt time.Time
+}
+
+func (schedule *daySchedule) isOpen(clock string) bool {
+	if schedule.allDay {
+		return true
+	}
+	for _, hour := range schedule.hours {
+		if clock >= hour.StartTime && clock < hour.EndTime {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpen 分组当前是否在营业时间内
+func (service *businessHoursService) IsOpen(gid int64) bool {
+	return service.IsOpenAt(gid, time.Now())
+}
+
+// IsOpenAt 指定时间是否在营业时间内，按分组设置的时区计算
+// 节假日优先于每周的营业时间，没有设置任何营业时间段时视为全天营业
+func (service *businessHoursService) IsOpenAt(gid int64, t time.Time) bool {
+	schedule := service.getSchedule(gid, t)
+	return schedule.isOpen(t.In(schedule.location).Format("15:04"))
+}
+
+// Forget 清除分组缓存的营业时间，修改营业时间或节假日后调用
+func (service *businessHoursService) Forget(gid int64) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	delete(service.schedules, gid)
+}
+
+// ShouldNoticeClosed 非营业时间是否需要提示用户，同一用户在间隔内只提示一次
+func (service *businessHoursService) ShouldNoticeClosed(uid int64) bool {
+	ctx := context.Background()
+	cmd := databases.Redis.SetNX(ctx, fmt.Sprintf(closedNoticeKey, uid), 1, closedNoticeDuration)
+	return cmd.Val()
+}
+
+// 获取分组在指定时间当天的营业时间段，优先使用缓存
+func (service *businessHoursService) getSchedule(gid int64, t time.Time) *daySchedule {
+	service.mutex.Lock()
+	schedule, ok := service.schedules[gid]
+	service.mutex.Unlock()
+	if ok && time.Now().Before(schedule.expireAt) &&
+		t.In(schedule.location).Format("2006-01-02") == schedule.date {
+		return schedule
+	}
+	schedule = service.loadSchedule(gid, t)
+	service.mutex.Lock()
+	service.schedules[gid] = schedule
+	service.mutex.Unlock()
+	return schedule
+}
+
+func (service *businessHoursService) loadSchedule(gid int64, t time.Time) *daySchedule {
+	location := SettingService.GetTimezone(gid)
+	t = t.In(location)
+	schedule := &daySchedule{
+		location: location,
+		date:     t.Format("2006-01-02"),
+		hours:    make([]*models.BusinessHour, 0),
+		expireAt: time.Now().Add(scheduleCacheDuration),
+	}
+	holiday := repositories.HolidayRepo.First([]*repositories.Where{
+		{
+			Filed: "group_id = ?",
+			Value: gid,
+		},
+		{
+			Filed: "date = ?",
+			Value: schedule.date,
+		},
+	}, []string{})
+	if holiday != nil {
+		if holiday.IsOpen {
+			schedule.hours = append(schedule.hours, &models.BusinessHour{
+				StartTime: holiday.StartTime,
+				EndTime:   holiday.EndTime,
+			})
+		}
+		return schedule
+	}
+	hours := repositories.BusinessHourRepo.GetByGroup(gid)
+	if len(hours) == 0 {
+		schedule.allDay = true
+		return schedule
+	}
+	for _, hour := range hours {
+		if hour.Weekday == int(t.Weekday()) {
+			schedule.hours = append(schedule.hours, hour)
+		}
+	}
+	return schedule
+}
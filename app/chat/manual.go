@@ -6,12 +6,28 @@ import (
 	"strconv"
 	"time"
 	"ws/app/databases"
+	"ws/app/models"
 
 	"github.com/go-redis/redis/v8"
 )
 
 const (
 	manualUserKey = "user:%d:manual"
+	// 单个队列的待人工接入sortSet，分组的sortSet包含所有队列的用户
+	manualQueueUserKey = "user:%d:manual:%d"
+	// 用户 => 所在队列 hashes
+	manualUserQueueKey = "user:%d:manual:queue"
+	// 用户 => 排队中断开连接的时间 hashes
+	manualUserOfflineKey = "user:%d:manual:offline"
+	// 分组最近的平均接入等待时长
+	manualWaitTimeKey = "user:%d:manual:wait-time"
+)
+
+const (
+	// 计算平均接入等待时长的会话数
+	waitTimeSampleSize = 20
+	// 没有历史会话时的默认等待时长
+	defaultWaitTime = 60
 )
 
 var (
@@ -25,14 +41,48 @@ func (manual manualService) getManualKey(gid int64) string {
 	return fmt.Sprintf(manualUserKey, gid)
 }
 
+func (manual manualService) getQueueKey(gid int64, queueId int64) string {
+	return fmt.Sprintf(manualQueueUserKey, gid, queueId)
+}
+
 // Add 加入到待人工接入sortSet
-func (manual *manualService) Add(uid int64, gid int64) error {
+// score为有效的加入时间，每级优先级相当于提前设置的时长加入
+// 等待超过该时长的普通用户仍排在新加入的高优先级用户前面，不会一直等待
+func (manual *manualService) Add(uid int64, gid int64, queueId int64, priority int8) error {
 	ctx := context.Background()
-	cmd := databases.Redis.ZAdd(ctx, manual.getManualKey(gid), &redis.Z{
-		Score:  float64(time.Now().Unix()),
+	score := time.Now().Unix() - int64(priority)*SettingService.GetPriorityDuration(gid)
+	z := &redis.Z{
+		Score:  float64(score),
 		Member: uid,
-	})
-	return cmd.Err()
+	}
+	pipe := databases.Redis.TxPipeline()
+	pipe.ZAdd(ctx, manual.getManualKey(gid), z)
+	pipe.ZAdd(ctx, manual.getQueueKey(gid, queueId), z)
+	pipe.HSet(ctx, fmt.Sprintf(manualUserQueueKey, gid), uid, queueId)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetQueueId 获取用户所在的队列
+func (manual *manualService) GetQueueId(uid int64, gid int64) int64 {
+	ctx := context.Background()
+	cmd := databases.Redis.HGet(ctx, fmt.Sprintf(manualUserQueueKey, gid), strconv.FormatInt(uid, 10))
+	queueId, _ := cmd.Int64()
+	return queueId
+}
+
+// GetQueueCountByTime 获取队列中指定时间的数量
+func (manual *manualService) GetQueueCountByTime(gid int64, queueId int64, min string, max string) int64 {
+	ctx := context.Background()
+	cmd := databases.Redis.ZCount(ctx, manual.getQueueKey(gid, queueId), min, max)
+	return cmd.Val()
+}
+
+// GetQueueTotalCount 获取队列中待人工接入的数量
+func (manual *manualService) GetQueueTotalCount(gid int64, queueId int64) int64 {
+	ctx := context.Background()
+	cmd := databases.Redis.ZCard(ctx, manual.getQueueKey(gid, queueId))
+	return cmd.Val()
 }
 
 // IsIn 是否在待人工接入列表中
@@ -48,10 +98,38 @@ func (manual *manualService) IsIn(uid int64, gid int64) bool {
 // Remove 从待人工接入列表中移除
 func (manual *manualService) Remove(uid int64, gid int64) error {
 	ctx := context.Background()
-	cmd := databases.Redis.ZRem(ctx, manual.getManualKey(gid), uid)
+	queueId := manual.GetQueueId(uid, gid)
+	pipe := databases.Redis.TxPipeline()
+	pipe.ZRem(ctx, manual.getManualKey(gid), uid)
+	pipe.ZRem(ctx, manual.getQueueKey(gid, queueId), uid)
+	pipe.HDel(ctx, fmt.Sprintf(manualUserQueueKey, gid), strconv.FormatInt(uid, 10))
+	pipe.HDel(ctx, fmt.Sprintf(manualUserOfflineKey, gid), strconv.FormatInt(uid, 10))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetOffline 记录排队中的用户断开连接的时间
+func (manual *manualService) SetOffline(uid int64, gid int64) error {
+	ctx := context.Background()
+	cmd := databases.Redis.HSet(ctx, fmt.Sprintf(manualUserOfflineKey, gid), uid, time.Now().Unix())
+	return cmd.Err()
+}
+
+// RemoveOffline 用户重新连接
+func (manual *manualService) RemoveOffline(uid int64, gid int64) error {
+	ctx := context.Background()
+	cmd := databases.Redis.HDel(ctx, fmt.Sprintf(manualUserOfflineKey, gid), strconv.FormatInt(uid, 10))
 	return cmd.Err()
 }
 
+// GetOfflineTime 获取排队中的用户断开连接的时间，0为未断开
+func (manual *manualService) GetOfflineTime(uid int64, gid int64) int64 {
+	ctx := context.Background()
+	cmd := databases.Redis.HGet(ctx, fmt.Sprintf(manualUserOfflineKey, gid), strconv.FormatInt(uid, 10))
+	t, _ := cmd.Int64()
+	return t
+}
+
 // GetTotalCount 获取待人工接入的数量
 func (manual *manualService) GetTotalCount(gid int64) int64 {
 	ctx := context.Background()
@@ -78,7 +156,7 @@ func (manual *manualService) GetByTime(gid int64, min string, max string) []stri
 	return cmd.Val()
 }
 
-// GetTime 获取加入时间
+// GetTime 获取有效的加入时间
 func (manual *manualService) GetTime(uid int64, gid int64) float64 {
 	ctx := context.Background()
 	cmd := databases.Redis.ZScore(ctx, manual.getManualKey(gid), strconv.FormatInt(uid, 10))
@@ -118,3 +196,43 @@ func (manual *manualService) GetBySource(gid int64, Offset, count int) []int64 {
 	}
 	return uid
 }
+
+// GetAverageWaitTime 最近接入的会话的平均等待时长(接入时间-加入队列时间)，缓存一分钟
+func (manual *manualService) GetAverageWaitTime(gid int64) int64 {
+	ctx := context.Background()
+	key := fmt.Sprintf(manualWaitTimeKey, gid)
+	if t, err := databases.Redis.Get(ctx, key).Int64(); err == nil {
+		return t
+	}
+	sessions := make([]*models.ChatSession, 0, waitTimeSampleSize)
+	databases.Db.Where("group_id = ?", gid).
+		Where("accepted_at > ?", 0).
+		Where("queried_at > ?", 0).
+		Where("accepted_at >= queried_at").
+		Order("accepted_at desc").
+		Limit(waitTimeSampleSize).
+		Find(&sessions)
+	var average int64 = defaultWaitTime
+	if len(sessions) > 0 {
+		var total int64
+		for _, session := range sessions {
+			total += session.AcceptedAt - session.QueriedAt
+		}
+		average = total / int64(len(sessions))
+	}
+	databases.Redis.Set(ctx, key, average, time.Minute)
+	return average
+}
+
+// EstimateWaitTime 预计等待时长(秒)
+// ahead为前面等待的人数，spare为可接入的空闲名额，没有在线客服时返回-1
+func (manual *manualService) EstimateWaitTime(average int64, ahead int64, online int, spare int) int64 {
+	if online == 0 {
+		return -1
+	}
+	if spare > 0 {
+		return average * (ahead + 1) / int64(spare)
+	}
+	// 接待已满时需要先等待客服结束会话，按多排一轮计算
+	return average * (ahead + 1 + int64(online)) / int64(online)
+}
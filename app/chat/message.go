@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"errors"
+	"time"
+	"ws/app/models"
+	"ws/app/repositories"
+)
+
+var MessageService = &messageService{}
+
+type messageService struct {
+}
+
+// ValidateReply 验证引用的消息属于同一会话(同一用户)且未撤回
+func (messageService *messageService) ValidateReply(message *models.Message) error {
+	if message.ReplyTo == 0 {
+		return nil
+	}
+	reply := repositories.MessageRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: message.ReplyTo,
+		},
+		{
+			Filed: "user_id = ?",
+			Value: message.UserId,
+		},
+		{
+			Filed: "group_id = ?",
+			Value: message.GroupId,
+		},
+	}, []string{})
+	if reply == nil {
+		return errors.New("引用的消息不存在")
+	}
+	if reply.IsRecalled() {
+		return errors.New("引用的消息已撤回")
+	}
+	message.Reply = reply
+	return nil
+}
+
+// Recall 撤回消息
+// 只能撤回用户或客服发送的消息(文本、图片、附件等)，且需在设置的时间内
+func (messageService *messageService) Recall(message *models.Message) error {
+	if message.IsRecalled() {
+		return errors.New("消息已撤回")
+	}
+	if message.Type != models.TypeText && message.Type != models.TypeImage && !models.IsPayloadType(message.Type) {
+		return errors.New("该消息无法撤回")
+	}
+	duration := SettingService.GetRecallDuration(message.GroupId)
+	if message.ReceivedAT+duration < time.Now().Unix() {
+		return errors.New("消息已超过可撤回时间")
+	}
+	message.RecalledAt = time.Now().Unix()
+	return repositories.MessageRepo.Save(message)
+}
+
+// Edit 编辑消息
+// 只能编辑未撤回的文本消息，编辑前的内容保存到编辑记录中
+func (messageService *messageService) Edit(message *models.Message, content string) error {
+	if message.IsRecalled() {
+		return errors.New("消息已撤回")
+	}
+	if message.Type != models.TypeText {
+		return errors.New("只能编辑文本消息")
+	}
+	if message.Content == content {
+		return errors.New("消息内容未修改")
+	}
+	now := time.Now().Unix()
+	err := repositories.RevisionRepo.Save(&models.MessageRevision{
+		MessageId: message.Id,
+		AdminId:   message.AdminId,
+		Content:   message.Content,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+	message.Content = content
+	message.EditedAt = now
+	return repositories.MessageRepo.Save(message)
+}
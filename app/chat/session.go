@@ -1,7 +1,9 @@
 package chat
 
 import (
+	"errors"
 	"time"
+	"ws/app/models"
 	"ws/app/repositories"
 )
 
@@ -10,12 +12,15 @@ type sessionService struct {
 
 }
 
-// Close 关闭会话
-func (sessionService *sessionService) Close(sessionId uint64, isRemoveUser bool, updateTime bool) {
+// Close 关闭会话并记录关闭原因，已关闭的会话不再更新关闭时间和原因
+func (sessionService *sessionService) Close(sessionId uint64, reason string, isRemoveUser bool, updateTime bool) {
 	session := repositories.ChatSessionRepo.FirstById(sessionId)
 	if session != nil {
-		session.BrokeAt = time.Now().Unix()
-		repositories.ChatSessionRepo.Save(session)
+		if session.BrokeAt == 0 {
+			session.BrokeAt = time.Now().Unix()
+			session.CloseReason = reason
+			repositories.ChatSessionRepo.Save(session)
+		}
 		if isRemoveUser {
 			_ = AdminService.RemoveUser(session.AdminId, session.UserId)
 		}
@@ -28,3 +33,25 @@ func (sessionService *sessionService) Close(sessionId uint64, isRemoveUser bool,
 
 
 
+
+// Cancel 取消排队中的会话并移出待人工接入列表，记录取消原因
+func (sessionService *sessionService) Cancel(session *models.ChatSession, reason string) {
+	session.CanceledAt = time.Now().Unix()
+	session.CancelReason = reason
+	repositories.ChatSessionRepo.Save(session)
+	_ = ManualService.Remove(session.UserId, session.GroupId)
+}
+
+// Rate 用户对已接入的会话进行满意度评价，每个会话只能评价一次
+func (sessionService *sessionService) Rate(session *models.ChatSession, rating int8, comment string) error {
+	if session.AcceptedAt == 0 {
+		return errors.New("会话未接入，无法评价")
+	}
+	if session.RatedAt > 0 {
+		return errors.New("已评价，请勿重复提交")
+	}
+	session.Rating = rating
+	session.RatingComment = comment
+	session.RatedAt = time.Now().Unix()
+	return repositories.ChatSessionRepo.Save(session)
+}
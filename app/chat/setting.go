@@ -2,6 +2,7 @@ package chat
 
 import (
 	"strconv"
+	"time"
 	"ws/app/databases"
 	"ws/app/models"
 )
@@ -31,6 +32,19 @@ func (settingService *settingService) GetOfflineDuration(gid int64) int64 {
 }
 
 
+// GetRecallDuration 消息发送后多久内可以撤回
+func (settingService *settingService) GetRecallDuration(gid int64) int64 {
+	setting := &models.ChatSetting{}
+	databases.Db.Where("group_id = ?", gid).Where("name = ?", models.MinuteToRecall).First(setting)
+	if setting.Id != 0 {
+		min, err := strconv.ParseInt(setting.Value, 10, 64)
+		if err == nil {
+			return min * 60
+		}
+	}
+	return 2 * 60
+}
+
 // GetIsAutoTransferManual 是否自动转接人工客服
 func (settingService *settingService) GetIsAutoTransferManual(gid int64) bool {
 	setting := &models.ChatSetting{}
@@ -44,3 +58,110 @@ func (settingService *settingService) GetIsAutoTransferManual(gid int64) bool {
 	return true
 }
 
+// GetIsSingleSession 是否只允许单端登录，开启后新连接会断开旧连接
+func (settingService *settingService) GetIsSingleSession(gid int64) bool {
+	setting := &models.ChatSetting{}
+	databases.Db.Where("group_id = ?", gid).Where("name = ?", models.IsSingleSession).First(setting)
+	if setting.Id != 0 {
+		v, err := strconv.ParseInt(setting.Value, 10, 64)
+		if err == nil {
+			return v > 0
+		}
+	}
+	return false
+}
+
+// GetAwayDuration 客服空闲多久自动切换为离开，0为不自动切换
+func (settingService *settingService) GetAwayDuration(gid int64) int64 {
+	setting := &models.ChatSetting{}
+	databases.Db.Where("group_id = ?", gid).Where("name = ?", models.MinuteToAway).First(setting)
+	if setting.Id != 0 {
+		min, err := strconv.ParseInt(setting.Value, 10, 64)
+		if err == nil {
+			return min * 60
+		}
+	}
+	return 10 * 60
+}
+
+// GetAssignStrategy 自动分配的策略
+func (settingService *settingService) GetAssignStrategy(gid int64) string {
+	setting := &models.ChatSetting{}
+	databases.Db.Where("group_id = ?", gid).Where("name = ?", models.AssignStrategy).First(setting)
+	if setting.Value == AssignLeastActive {
+		return AssignLeastActive
+	}
+	return AssignRoundRobin
+}
+
+// GetMaxChats 客服默认的最大同时接待人数，0为不限制
+func (settingService *settingService) GetMaxChats(gid int64) int {
+	setting := &models.ChatSetting{}
+	databases.Db.Where("group_id = ?", gid).Where("name = ?", models.MaxChats).First(setting)
+	if setting.Id != 0 {
+		max, err := strconv.Atoi(setting.Value)
+		if err == nil && max > 0 {
+			return max
+		}
+	}
+	return 0
+}
+
+// GetPriorityDuration 每级优先级在排队时提前的时长，0为不启用优先级
+func (settingService *settingService) GetPriorityDuration(gid int64) int64 {
+	setting := &models.ChatSetting{}
+	databases.Db.Where("group_id = ?", gid).Where("name = ?", models.MinuteToPriority).First(setting)
+	if setting.Id != 0 {
+		min, err := strconv.ParseInt(setting.Value, 10, 64)
+		if err == nil {
+			return min * 60
+		}
+	}
+	return 5 * 60
+}
+
+// GetQueueTimeout 排队的最大等待时长，0为不限制
+func (settingService *settingService) GetQueueTimeout(gid int64) int64 {
+	setting := &models.ChatSetting{}
+	databases.Db.Where("group_id = ?", gid).Where("name = ?", models.MinuteToQueueTimeout).First(setting)
+	if setting.Id != 0 {
+		min, err := strconv.ParseInt(setting.Value, 10, 64)
+		if err == nil {
+			return min * 60
+		}
+	}
+	return 0
+}
+
+// GetAbandonDuration 排队的用户断开连接多久后移出队列
+func (settingService *settingService) GetAbandonDuration(gid int64) int64 {
+	setting := &models.ChatSetting{}
+	databases.Db.Where("group_id = ?", gid).Where("name = ?", models.MinuteToAbandon).First(setting)
+	if setting.Id != 0 {
+		min, err := strconv.ParseInt(setting.Value, 10, 64)
+		if err == nil && min > 0 {
+			return min * 60
+		}
+	}
+	return 3 * 60
+}
+
+// GetTimezone 营业时间使用的时区，未设置或设置错误时使用服务器的时区
+func (settingService *settingService) GetTimezone(gid int64) *time.Location {
+	setting := &models.ChatSetting{}
+	databases.Db.Where("group_id = ?", gid).Where("name = ?", models.Timezone).First(setting)
+	if setting.Value != "" {
+		loc, err := time.LoadLocation(setting.Value)
+		if err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// GetIsSurvey 会话结束后是否发送满意度评价
+func (settingService *settingService) GetIsSurvey(gid int64) bool {
+	setting := &models.ChatSetting{}
+	databases.Db.Where("group_id = ?", gid).Where("name = ?", models.IsSurvey).First(setting)
+	return setting.Value == "1"
+}
@@ -47,12 +47,20 @@ func (transferService *transferService) Cancel(transfer *models.ChatTransfer) er
 }
 
 // Create 创建转接
+// 转接对象的接待人数已达上限时无法转接
 func (transferService *transferService) Create(fromId int64, toId int64, uid int64, remark string) error {
+	toAdmin := repositories.AdminRepo.FirstById(toId)
+	if toAdmin == nil {
+		return errors.New("admin_not_exist")
+	}
+	if err := AdminService.CheckCapacity(toAdmin); err != nil {
+		return err
+	}
 	session := repositories.ChatSessionRepo.FirstActiveByUser(uid, fromId)
-	SessionService.Close(session.Id, true, true)
 	if session == nil {
 		return errors.New("invalid user")
 	}
+	SessionService.Close(session.Id, models.CloseReasonTransfer, true, true)
 	now := time.Now()
 	newSession := repositories.ChatSessionRepo.Create(uid, session.GroupId, models.ChatSessionTypeTransfer)
 	transfer := &models.ChatTransfer{
@@ -9,6 +9,8 @@ import (
 const (
 	// 用户 => 客服 hashes
 	user2AdminHashKey = "user-to-admin"
+	// 用户 => 指定的人工队列 hashes
+	user2QueueHashKey = "user-to-queue"
 )
 
 var UserService = &userService{}
@@ -43,4 +45,20 @@ func (userService *userService) GetValidAdmin(uid int64) int64 {
 		}
 	}
 	return 0
-}
\ No newline at end of file
+}
+// SetQueue 设置用户转人工时进入的队列，0为默认队列
+func (userService *userService) SetQueue(uid int64, queueId int64) error {
+	ctx := context.Background()
+	if queueId == 0 {
+		return databases.Redis.HDel(ctx, user2QueueHashKey, strconv.FormatInt(uid, 10)).Err()
+	}
+	return databases.Redis.HSet(ctx, user2QueueHashKey, uid, queueId).Err()
+}
+
+// GetQueue 获取用户指定的队列
+func (userService *userService) GetQueue(uid int64) int64 {
+	ctx := context.Background()
+	cmd := databases.Redis.HGet(ctx, user2QueueHashKey, strconv.FormatInt(uid, 10))
+	queueId, _ := cmd.Int64()
+	return queueId
+}
@@ -0,0 +1,30 @@
+package cron
+
+import (
+	"ws/app/http/websocket"
+	"ws/app/log"
+	"ws/app/repositories"
+)
+
+// 定时自动分配，处理客服状态变化时遗漏的待接入用户
+func assignUsers() {
+	log.Log.WithField("type", "cron").Info("<start-job:assign-users>")
+	for _, gid := range getGroupIds() {
+		websocket.AdminManager.AutoAssign(gid)
+	}
+	log.Log.WithField("type", "cron").Info("<end-job:assign-users>")
+}
+
+// 获取所有客服所在的分组id
+func getGroupIds() []int64 {
+	admins := repositories.AdminRepo.Get([]*repositories.Where{}, -1, []string{}, []string{})
+	groups := make(map[int64]struct{})
+	ids := make([]int64, 0)
+	for _, admin := range admins {
+		if _, ok := groups[admin.GetGroupId()]; !ok {
+			groups[admin.GetGroupId()] = struct{}{}
+			ids = append(ids, admin.GetGroupId())
+		}
+	}
+	return ids
+}
@@ -10,6 +10,8 @@ func Serve() *gocron.Scheduler {
 	log.Log.WithField("a-type", "cron").Info("start")
 	s := gocron.NewScheduler(time.UTC)
 	s.Every(1).Minute().Do(closeSessions)
+	s.Every(1).Minute().Do(assignUsers)
+	s.Every(1).Minute().Do(checkWaitingUsers)
 	s.StartAsync()
 	return s
 }
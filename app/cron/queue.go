@@ -0,0 +1,15 @@
+package cron
+
+import (
+	"ws/app/http/websocket"
+	"ws/app/log"
+)
+
+// 定时检查排队超时和断开连接的用户
+func checkWaitingUsers() {
+	log.Log.WithField("type", "cron").Info("<start-job:check-waiting-users>")
+	for _, gid := range getGroupIds() {
+		websocket.UserManager.CheckWaitingUsers(gid)
+	}
+	log.Log.WithField("type", "cron").Info("<end-job:check-waiting-users>")
+}
@@ -5,6 +5,7 @@ import (
 	"ws/app/chat"
 	"ws/app/http/websocket"
 	"ws/app/log"
+	"ws/app/models"
 	"ws/app/repositories"
 )
 
@@ -33,10 +34,11 @@ func closeSessions() {
 					},
 				}, []string{"id desc"})
 				if session != nil {
-					chat.SessionService.Close(session.Id, false, false)
+					chat.SessionService.Close(session.Id, models.CloseReasonIdle, false, false)
 					noticeMessage := admin.GetBreakMessage(uid, session.Id)
 					websocket.UserManager.DeliveryMessage(noticeMessage, false)
 					repositories.MessageRepo.Save(noticeMessage)
+					websocket.UserManager.SendSurvey(session.Id)
 				}
 			}
 		}
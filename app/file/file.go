@@ -1,8 +1,13 @@
 package file
 
 import (
+	"errors"
+	"fmt"
 	"github.com/spf13/viper"
 	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
 )
 
 const (
@@ -22,6 +27,9 @@ type File struct {
 	FullUrl string
 	Path    string
 	Storage string
+	Name    string // 原始文件名
+	Size    int64
+	Mime    string
 }
 
 type Manager interface {
@@ -45,3 +53,85 @@ func Save(file *multipart.FileHeader, path string) (*File, error) {
 	disk := Disk(def)
 	return disk.Save(file, path)
 }
+
+// 聊天附件允许的扩展名及其对应的内容类型(http.DetectContentType的检测结果)
+var attachmentTypes = map[string][]string{
+	".jpg":  {"image/jpeg"},
+	".jpeg": {"image/jpeg"},
+	".png":  {"image/png"},
+	".gif":  {"image/gif"},
+	".webp": {"image/webp"},
+	".pdf":  {"application/pdf"},
+	".txt":  {"text/plain; charset=utf-8"},
+	".zip":  {"application/zip"},
+	".docx": {"application/zip"},
+	".xlsx": {"application/zip"},
+	".pptx": {"application/zip"},
+	".doc":  {"application/octet-stream"},
+	".xls":  {"application/octet-stream"},
+	".ppt":  {"application/octet-stream"},
+	".mp3":  {"audio/mpeg"},
+	".wav":  {"audio/wave"},
+	".ogg":  {"application/ogg"},
+	".amr":  {"application/octet-stream"},
+	".m4a":  {"video/mp4"},
+	".mp4":  {"video/mp4"},
+	".webm": {"video/webm"},
+}
+
+// 聊天图片允许的扩展名及其对应的内容类型
+var imageTypes = map[string][]string{
+	".jpg":  {"image/jpeg"},
+	".jpeg": {"image/jpeg"},
+	".png":  {"image/png"},
+	".gif":  {"image/gif"},
+	".webp": {"image/webp"},
+}
+
+var ErrTypeNotAllowed = errors.New("不支持的文件类型")
+
+// SaveAttachment 保存聊天附件，只允许白名单内的类型，按分组存放在chat/files下
+// chat/files下的文件访问时一律以下载方式返回
+func SaveAttachment(file *multipart.FileHeader, gid int64) (*File, error) {
+	if !isAllowed(file, attachmentTypes) {
+		return nil, ErrTypeNotAllowed
+	}
+	return Save(file, fmt.Sprintf("chat/files/%d", gid))
+}
+
+// SaveImage 保存聊天图片，只允许常见的图片类型
+func SaveImage(file *multipart.FileHeader, path string) (*File, error) {
+	if !isAllowed(file, imageTypes) {
+		return nil, ErrTypeNotAllowed
+	}
+	return Save(file, path)
+}
+
+// 扩展名与实际内容均在白名单内才允许保存
+func isAllowed(file *multipart.FileHeader, allowed map[string][]string) bool {
+	types, ok := allowed[strings.ToLower(path.Ext(file.Filename))]
+	if !ok {
+		return false
+	}
+	mime := detectMime(file)
+	for _, t := range types {
+		if t == mime {
+			return true
+		}
+	}
+	return false
+}
+
+// 根据文件内容检测mime类型
+func detectMime(file *multipart.FileHeader) string {
+	f, err := file.Open()
+	if err != nil {
+		return ""
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	b := make([]byte, 512)
+	n, _ := f.Read(b)
+	return http.DetectContentType(b[:n])
+}
@@ -83,5 +83,8 @@ func (local *local) Save(file *multipart.FileHeader, relativePath string) (*File
 		Path:    relativeName,
 		FullUrl: local.Url(relativeName),
 		Storage: StorageLocal,
+		Name:    file.Filename,
+		Size:    file.Size,
+		Mime:    detectMime(file),
 	}, nil
 }
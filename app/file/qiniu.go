@@ -57,5 +57,8 @@ func (qiniu *qiniu) Save(file *multipart.FileHeader, relativePath string) (*File
 		FullUrl: qiniu.Url(key),
 		Path:    key,
 		Storage: StorageQiniu,
+		Name:    file.Filename,
+		Size:    file.Size,
+		Mime:    detectMime(file),
 	}, nil
 }
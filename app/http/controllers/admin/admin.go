@@ -3,6 +3,7 @@ package admin
 import (
 	"strconv"
 	"ws/app/chat"
+	"ws/app/contract"
 	"ws/app/http/requests"
 	"ws/app/http/responses"
 	"ws/app/http/websocket"
@@ -21,20 +22,26 @@ func (handle *AdminsHandler) Index(c *gin.Context) {
 	where := requests.GetFilterWhere(c, map[string]interface{}{
 		"username": "=",
 	})
-	admin := requests.GetAdmin(c)
+	viewer := requests.GetAdmin(c)
 	where = append(where, &repositories.Where{
 		Filed: "group_id = ?",
-		Value: admin.GetGroupId(),
+		Value: viewer.GetGroupId(),
 	})
 	p := repositories.AdminRepo.Paginate(c, where, []string{}, []string{"id desc"})
 	_ = p.DataFormat(func(admin *models.Admin) interface{} {
-		return &resource.Admin{
+		json := &resource.Admin{
 			Avatar:        admin.GetAvatarUrl(),
 			Username:      admin.Username,
-			Online:        websocket.AdminManager.ConnExist(admin),
 			Id:            admin.ID,
 			AcceptedCount: chat.AdminService.GetActiveCount(admin.GetPrimaryKey()),
+			MaxChats:      chat.AdminService.GetMaxChats(admin),
 		}
+		// 隐身的客服对其他客服显示为离线
+		if isVisibleTo(viewer, admin) {
+			json.Online = websocket.AdminManager.ConnExist(admin)
+			json.Status = admin.GetSetting().GetStatus()
+		}
+		return json
 	})
 	responses.RespPagination(c, p)
 }
@@ -97,9 +104,15 @@ func (handle *AdminsHandler) Show(c *gin.Context) {
 		"admin": resource.Admin{
 			Avatar:        admin.GetAvatarUrl(),
 			Username:      admin.GetUsername(),
-			Online:        websocket.AdminManager.IsOnline(admin),
+			Online:        isVisibleTo(u, admin) && websocket.AdminManager.IsOnline(admin),
 			Id:            admin.GetPrimaryKey(),
 			AcceptedCount: chat.AdminService.GetActiveCount(admin.GetPrimaryKey()),
+			MaxChats:      chat.AdminService.GetMaxChats(admin),
 		},
 	})
 }
+
+// 客服的在线状态对viewer是否可见，隐身的客服只有自己可见
+func isVisibleTo(viewer contract.User, admin *models.Admin) bool {
+	return viewer.GetPrimaryKey() == admin.GetPrimaryKey() || admin.GetSetting().IsVisible()
+}
@@ -92,6 +92,17 @@ func (handler *AutoMessageHandler) Store(c *gin.Context) {
 		}
 		message.Content = string(jsonBytes)
 	}
+	if message.Type == models.TypeButtons {
+		jsonBytes, err := json.Marshal(&models.ButtonsContent{
+			Content: form.Content,
+			Buttons: form.Buttons,
+		})
+		if err != nil {
+			responses.RespError(c, err.Error())
+			return
+		}
+		message.Content = string(jsonBytes)
+	}
 	repositories.AutoMessageRepo.Save(message)
 	responses.RespSuccess(c, message)
 }
@@ -150,6 +161,17 @@ func (handler *AutoMessageHandler) Update(c *gin.Context) {
 		}
 		message.Content = string(jsonBytes)
 	}
+	if message.Type == models.TypeButtons {
+		jsonBytes, err := json.Marshal(&models.ButtonsContent{
+			Content: form.Content,
+			Buttons: form.Buttons,
+		})
+		if err != nil {
+			responses.RespError(c, err.Error())
+			return
+		}
+		message.Content = string(jsonBytes)
+	}
 	repositories.AutoMessageRepo.Save(message)
 	responses.RespSuccess(c, message)
 }
@@ -101,6 +101,10 @@ func (handle *AutoRuleHandler) Store(c *gin.Context) {
 		responses.RespValidateFail(c, err.Error())
 		return
 	}
+	if !isValidTransferQueue(admin.GetGroupId(), form) {
+		responses.RespValidateFail(c, "队列不存在")
+		return
+	}
 	if form.ReplyType == models.ReplyTypeTransfer {
 		form.Scenes = []string{
 			models.SceneNotAccepted,
@@ -156,6 +160,10 @@ func (handle *AutoRuleHandler) Update(c *gin.Context) {
 		responses.RespValidateFail(c, err.Error())
 		return
 	}
+	if !isValidTransferQueue(rule.GroupId, form) {
+		responses.RespValidateFail(c, "队列不存在")
+		return
+	}
 	repositories.AutoRuleRepo.DeleteScene(rule)
 	if form.ReplyType == models.ReplyTypeTransfer {
 		form.Scenes = []string{
@@ -272,3 +280,11 @@ func (handler *SystemRuleHandler) Update(c *gin.Context) {
 	}
 	responses.RespSuccess(c, m)
 }
+
+// 转人工时key为指定的队列id，为空则进入默认队列，指定的队列必须属于当前分组
+func isValidTransferQueue(gid int64, form requests.AutoRuleForm) bool {
+	if form.ReplyType != models.ReplyTypeTransfer || form.Key == "" || form.Key == "0" {
+		return true
+	}
+	return repositories.ChatQueueRepo.FirstByGroup(gid, form.Key) != nil
+}
@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"ws/app/chat"
+	"ws/app/http/requests"
+	"ws/app/http/responses"
+	"ws/app/models"
+	"ws/app/repositories"
+	"ws/app/resource"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BusinessHourHandler struct {
+}
+
+// Index 每周的营业时间和时区
+func (handler *BusinessHourHandler) Index(c *gin.Context) {
+	gid := requests.GetAdmin(c).GetGroupId()
+	hours := repositories.BusinessHourRepo.GetByGroup(gid)
+	data := make([]*resource.BusinessHour, 0, len(hours))
+	for _, hour := range hours {
+		data = append(data, hour.ToJson())
+	}
+	responses.RespSuccess(c, gin.H{
+		"timezone": chat.SettingService.GetTimezone(gid).String(),
+		"hours":    data,
+		"is_open":  chat.BusinessHoursService.IsOpen(gid),
+	})
+}
+
+// Update 更新每周的营业时间和时区，不设置任何时间段为全天营业
+func (handler *BusinessHourHandler) Update(c *gin.Context) {
+	form := requests.BusinessHoursForm{}
+	err := c.ShouldBind(&form)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	gid := requests.GetAdmin(c).GetGroupId()
+	hours := make([]*models.BusinessHour, 0, len(form.Hours))
+	for _, item := range form.Hours {
+		hours = append(hours, &models.BusinessHour{
+			GroupId:   gid,
+			Weekday:   item.Weekday,
+			StartTime: item.StartTime,
+			EndTime:   item.EndTime,
+		})
+	}
+	err = repositories.BusinessHourRepo.Replace(gid, hours)
+	if err != nil {
+		responses.RespError(c, err.Error())
+		return
+	}
+	setting := repositories.ChatSettingRepo.First([]*repositories.Where{
+		{
+			Filed: "group_id = ?",
+			Value: gid,
+		},
+		{
+			Filed: "name = ?",
+			Value: models.Timezone,
+		},
+	}, []string{})
+	if setting == nil {
+		setting = &models.ChatSetting{
+			Name:    models.Timezone,
+			Title:   "营业时间的时区",
+			GroupId: gid,
+			Type:    "text",
+		}
+	}
+	setting.Value = form.Timezone
+	repositories.ChatSettingRepo.Save(setting)
+	chat.BusinessHoursService.Forget(gid)
+	responses.RespSuccess(c, gin.H{})
+}
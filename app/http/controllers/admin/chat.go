@@ -96,7 +96,7 @@ func (handle *ChatHandler) GetHistoryMessage(c *gin.Context) {
 			})
 		}
 	}
-	messages := repositories.MessageRepo.Get(wheres, 20, []string{"User", "Admin"}, []string{"id desc"})
+	messages := repositories.MessageRepo.Get(wheres, 20, []string{"User", "Admin", "Reply"}, []string{"id desc"})
 	res := make([]*resource.Message, len(messages), len(messages))
 	msgIds := make([]int64, len(messages), len(messages))
 	for i, m := range messages {
@@ -182,7 +182,7 @@ func (handle *ChatHandler) ChatUserList(c *gin.Context) {
 			Filed: "source in ?",
 			Value: []int{models.SourceAdmin, models.SourceUser},
 		},
-	}, -1, []string{"User", "Admin"}, []string{"id desc"})
+	}, -1, []string{"User", "Admin", "Reply"}, []string{"id desc"})
 	messageIds := make([]int64, len(messages), len(messages))
 	for _, u := range resp {
 		for i, m := range messages {
@@ -255,10 +255,24 @@ func (handle *ChatHandler) AcceptUser(c *gin.Context) {
 		responses.RespNotFound(c)
 		return
 	}
+	// 与自动分配互斥，防止同一用户被重复接入
+	if !chat.AssignService.Lock(user.GetPrimaryKey()) {
+		responses.RespFail(c, "user had been accepted", 10001)
+		return
+	}
+	defer chat.AssignService.Unlock(user.GetPrimaryKey())
 	if chat.UserService.GetValidAdmin(user.GetPrimaryKey()) != 0 {
 		responses.RespFail(c, "user had been accepted", 10001)
 		return
 	}
+	if !repositories.ChatQueueRepo.IsMember(session.QueueId, admin.GetPrimaryKey()) {
+		responses.RespFail(c, "不是该队列的成员，无法接入", 10003)
+		return
+	}
+	if err := chat.AdminService.CheckCapacity(admin); err != nil {
+		responses.RespFail(c, err.Error(), 10002)
+		return
+	}
 	if session.Type == models.ChatSessionTypeTransfer {
 		transferAdminId := chat.TransferService.GetUserTransferId(user.GetPrimaryKey())
 		if transferAdminId == 0 {
@@ -294,74 +308,7 @@ func (handle *ChatHandler) AcceptUser(c *gin.Context) {
 		_ = chat.TransferService.RemoveUser(user.GetPrimaryKey())
 		websocket.AdminManager.NoticeUserTransfer(admin)
 	}
-	unSendMsg := repositories.MessageRepo.GetUnSend([]*repositories.Where{
-		{
-			Filed: "user_id = ?",
-			Value: user.GetPrimaryKey(),
-		},
-		{
-			Filed: "session_id = ?",
-			Value: session.Id,
-		},
-	})
-	session.AcceptedAt = time.Now().Unix()
-	session.AdminId = admin.GetPrimaryKey()
-	repositories.ChatSessionRepo.Save(session)
-	_ = chat.AdminService.AddUser(admin, user)
-	now := time.Now().Unix()
-	// 更新未发送的消息
-	repositories.MessageRepo.Update([]*repositories.Where{
-		{
-			Filed: "user_id = ?",
-			Value: user.GetPrimaryKey(),
-		},
-		{
-			Filed: "source = ?",
-			Value: models.SourceUser,
-		},
-		{
-			Filed: "session_id = ?",
-			Value: session.Id,
-		},
-	}, map[string]interface{}{
-		"admin_id": admin.GetPrimaryKey(),
-		"send_at":  now,
-	})
-	messages := repositories.MessageRepo.Get([]*repositories.Where{
-		{
-			Filed: "user_id = ?",
-			Value: user.GetPrimaryKey(),
-		},
-		{
-			Filed: "admin_id = ?",
-			Value: admin.GetPrimaryKey(),
-		},
-		{
-			Filed: "source in ?",
-			Value: []int{models.SourceAdmin, models.SourceUser},
-		},
-	}, 20, []string{"User", "Admin"}, []string{"id desc"})
-	messageLength := len(messages)
-	chatUser := &resource.User{
-		ID:           user.GetPrimaryKey(),
-		Username:     user.GetUsername(),
-		LastChatTime: 0,
-		Messages:     make([]*resource.Message, messageLength, messageLength),
-		Avatar:       user.GetAvatarUrl(),
-	}
-	chatUser.Unread = len(unSendMsg)
-	chatUser.LastChatTime = time.Now().Unix()
-	chatUser.Online = websocket.UserManager.IsOnline(user)
-	noticeMessage := repositories.MessageRepo.NewNotice(session, admin.GetChatName()+"为您服务")
-
-	repositories.MessageRepo.Save(noticeMessage)
-	websocket.UserManager.DeliveryMessage(noticeMessage, false)
-	for index, m := range messages {
-		rm := m.ToJson()
-		chatUser.Messages[index] = rm
-	}
-	go websocket.AdminManager.BroadcastWaitingUser(user.GetGroupId())
-	go websocket.UserManager.BroadcastQueueLocation(user.GetGroupId())
+	chatUser := websocket.AdminManager.AcceptUser(admin, user, session)
 	responses.RespSuccess(c, chatUser)
 }
 
@@ -370,6 +317,19 @@ func (handle *ChatHandler) RemoveUser(c *gin.Context) {
 	uidStr := c.Param("id")
 	u := requests.GetAdmin(c)
 	admin, _ := u.(*models.Admin)
+	// 可选填写会话小结
+	form := requests.WrapUpForm{}
+	if c.Request.ContentLength > 0 {
+		err := c.ShouldBind(&form)
+		if err != nil {
+			responses.RespValidateFail(c, err.Error())
+			return
+		}
+		if form.WrapUpId > 0 && repositories.WrapUpCodeRepo.FirstByGroup(admin.GetGroupId(), form.WrapUpId) == nil {
+			responses.RespValidateFail(c, "小结分类不存在")
+			return
+		}
+	}
 	session := repositories.ChatSessionRepo.First([]*repositories.Where{
 		{
 			Filed: "user_id = ?",
@@ -386,8 +346,16 @@ func (handle *ChatHandler) RemoveUser(c *gin.Context) {
 			repositories.MessageRepo.Save(noticeMessage)
 			websocket.UserManager.DeliveryMessage(noticeMessage, false)
 		}
-		chat.SessionService.Close(session.Id, true, false)
+		if form.WrapUpId > 0 || form.Remark != "" {
+			session.WrapUpId = form.WrapUpId
+			session.WrapUpRemark = form.Remark
+			repositories.ChatSessionRepo.Save(session)
+		}
+		chat.SessionService.Close(session.Id, models.CloseReasonAdmin, true, false)
+		websocket.UserManager.SendSurvey(session.Id)
 	}
+	// 接待人数减少后可以继续自动分配
+	go websocket.AdminManager.AutoAssign(admin.GetGroupId())
 	responses.RespSuccess(c, nil)
 }
 
@@ -399,36 +367,86 @@ func (handle *ChatHandler) ReadAll(c *gin.Context) {
 	}{}
 	err := c.Bind(form)
 	admin := requests.GetAdmin(c)
-	wheres := []*repositories.Where{
-		{
-			Filed: "admin_id = ?",
-			Value: admin.GetPrimaryKey(),
-		},
-		{
-			Filed: "user_id = ?",
-			Value: form.Id,
-		},
-		{
-			Filed: "is_read = ?",
-			Value: 0,
-		},
-	}
 	if err == nil {
-		if form.MsgId > 0 {
-			wheres = append(wheres, &repositories.Where{
-				Filed: "id <= ?",
-				Value: form.MsgId,
-			})
+		msgId := repositories.MessageRepo.ReadByAdmin(admin.GetPrimaryKey(), form.Id, form.MsgId)
+		if msgId > 0 {
+			go websocket.UserManager.DeliveryRead(form.Id, admin.GetPrimaryKey(), msgId, false)
 		}
-		repositories.MessageRepo.Update(wheres, map[string]interface{}{
-			"is_read": 1,
-		})
 		responses.RespSuccess(c, gin.H{})
 	} else {
 		responses.RespValidateFail(c, "invalid params")
 	}
 }
 
+// RecallMessage 撤回消息
+func (handle *ChatHandler) RecallMessage(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		responses.RespValidateFail(c, "invalid params")
+		return
+	}
+	admin := requests.GetAdmin(c)
+	msg, err := websocket.AdminManager.RecallMessage(admin.GetPrimaryKey(), id)
+	if err != nil {
+		responses.RespFail(c, err.Error(), 500)
+		return
+	}
+	responses.RespSuccess(c, msg.ToJson())
+}
+
+// EditMessage 编辑消息
+func (handle *ChatHandler) EditMessage(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		responses.RespValidateFail(c, "invalid params")
+		return
+	}
+	form := &struct {
+		Content string `json:"content" binding:"required,max=512"`
+	}{}
+	err = c.ShouldBind(form)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	admin := requests.GetAdmin(c)
+	msg, err := websocket.AdminManager.EditMessage(admin.GetPrimaryKey(), id, form.Content)
+	if err != nil {
+		responses.RespFail(c, err.Error(), 500)
+		return
+	}
+	responses.RespSuccess(c, msg.ToJson())
+}
+
+// MessageRevisions 消息编辑记录
+func (handle *ChatHandler) MessageRevisions(c *gin.Context) {
+	admin := requests.GetAdmin(c)
+	msg := repositories.MessageRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: c.Param("id"),
+		},
+		{
+			Filed: "group_id = ?",
+			Value: admin.GetGroupId(),
+		},
+	}, []string{})
+	if msg == nil {
+		responses.RespNotFound(c)
+		return
+	}
+	revisions := repositories.RevisionRepo.Get([]*repositories.Where{
+		{
+			Filed: "message_id = ?",
+			Value: msg.Id,
+		},
+	}, -1, []string{}, []string{"id desc"})
+	res := slice.Map(revisions, func(index int, s *models.MessageRevision) *resource.MessageRevision {
+		return s.ToJson()
+	})
+	responses.RespSuccess(c, res)
+}
+
 // GetUserInfo 获取用户信息
 func (handle *ChatHandler) GetUserInfo(c *gin.Context) {
 	uidStr := c.Param("id")
@@ -458,11 +476,41 @@ func (handle *ChatHandler) GetUserInfo(c *gin.Context) {
 	}
 	responses.RespSuccess(c, gin.H{
 		"username": user.GetUsername(),
+		"priority": user.Priority,
+		"notes":    toNotesJson(repositories.NoteRepo.GetByUser(user.GetPrimaryKey())),
 		// other info
 	})
 
 }
 
+// UpdateUserPriority 设置用户的排队优先级，下次进入队列时生效
+func (handle *ChatHandler) UpdateUserPriority(c *gin.Context) {
+	form := requests.UserPriorityForm{}
+	err := c.ShouldBind(&form)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	user := repositories.UserRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: c.Param("id"),
+		},
+		{
+			Filed: "group_id = ?",
+			Value: requests.GetAdmin(c).GetGroupId(),
+		},
+	}, []string{})
+	if user == nil {
+		responses.RespNotFound(c)
+		return
+	}
+	repositories.UserRepo.UpdateById(user.GetPrimaryKey(), map[string]interface{}{
+		"priority": form.Priority,
+	})
+	responses.RespSuccess(c, gin.H{})
+}
+
 // TransferMessages 转接历史消息
 func (handle *ChatHandler) TransferMessages(c *gin.Context) {
 	admin := requests.GetAdmin(c)
@@ -485,7 +533,7 @@ func (handle *ChatHandler) TransferMessages(c *gin.Context) {
 			Filed: "session_id = ?",
 			Value: transfer.SessionId,
 		},
-	}, -1, []string{"Admin", "User"}, []string{"id desc"})
+	}, -1, []string{"Admin", "User", "Reply"}, []string{"id desc"})
 	res := slice.Map(messages, func(index int, s *models.Message) *resource.Message {
 		return s.ToJson()
 	})
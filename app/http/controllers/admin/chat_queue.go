@@ -0,0 +1,150 @@
+package admin
+
+import (
+	"ws/app/chat"
+	"ws/app/http/requests"
+	"ws/app/http/responses"
+	"ws/app/models"
+	"ws/app/repositories"
+	"ws/app/resource"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChatQueueHandler struct {
+}
+
+func (handler *ChatQueueHandler) Index(c *gin.Context) {
+	queues := repositories.ChatQueueRepo.Get([]*repositories.Where{
+		{
+			Filed: "group_id = ?",
+			Value: requests.GetAdmin(c).GetGroupId(),
+		},
+	}, -1, []string{"Admins"}, []string{"id"})
+	data := make([]*resource.ChatQueue, 0, len(queues))
+	for _, queue := range queues {
+		data = append(data, queue.ToJson())
+	}
+	responses.RespSuccess(c, data)
+}
+
+// Options 转人工规则可选择的队列
+func (handler *ChatQueueHandler) Options(c *gin.Context) {
+	queues := repositories.ChatQueueRepo.Get([]*repositories.Where{
+		{
+			Filed: "group_id = ?",
+			Value: requests.GetAdmin(c).GetGroupId(),
+		},
+	}, -1, []string{}, []string{"id"})
+	options := make([]resource.Options, 0, len(queues)+1)
+	options = append(options, resource.Options{
+		Value: 0,
+		Label: "默认队列",
+	})
+	for _, queue := range queues {
+		options = append(options, resource.Options{
+			Value: queue.Id,
+			Label: queue.Title,
+		})
+	}
+	responses.RespSuccess(c, options)
+}
+
+func (handler *ChatQueueHandler) Store(c *gin.Context) {
+	form := requests.ChatQueueForm{}
+	err := c.ShouldBind(&form)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	admin := requests.GetAdmin(c)
+	if repositories.ChatQueueRepo.FirstByName(admin.GetGroupId(), form.Name) != nil {
+		responses.RespValidateFail(c, "已存在同名的队列")
+		return
+	}
+	queue := &models.ChatQueue{
+		GroupId: admin.GetGroupId(),
+		Name:    form.Name,
+		Title:   form.Title,
+	}
+	repositories.ChatQueueRepo.Save(queue)
+	_ = repositories.ChatQueueRepo.ReplaceAdmins(queue, handler.getAdmins(admin.GetGroupId(), form.AdminIds))
+	responses.RespSuccess(c, queue.ToJson())
+}
+
+func (handler *ChatQueueHandler) Update(c *gin.Context) {
+	admin := requests.GetAdmin(c)
+	queue := repositories.ChatQueueRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: c.Param("id"),
+		},
+		{
+			Filed: "group_id = ?",
+			Value: admin.GetGroupId(),
+		},
+	}, []string{})
+	if queue == nil {
+		responses.RespNotFound(c)
+		return
+	}
+	form := requests.ChatQueueForm{}
+	err := c.ShouldBind(&form)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	exist := repositories.ChatQueueRepo.FirstByName(admin.GetGroupId(), form.Name)
+	if exist != nil && exist.Id != queue.Id {
+		responses.RespValidateFail(c, "已存在同名的其他队列")
+		return
+	}
+	queue.Name = form.Name
+	queue.Title = form.Title
+	repositories.ChatQueueRepo.Save(queue)
+	_ = repositories.ChatQueueRepo.ReplaceAdmins(queue, handler.getAdmins(admin.GetGroupId(), form.AdminIds))
+	responses.RespSuccess(c, queue.ToJson())
+}
+
+// Delete 删除队列，队列中还有等待的用户时无法删除
+func (handler *ChatQueueHandler) Delete(c *gin.Context) {
+	admin := requests.GetAdmin(c)
+	queue := repositories.ChatQueueRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: c.Param("id"),
+		},
+		{
+			Filed: "group_id = ?",
+			Value: admin.GetGroupId(),
+		},
+	}, []string{})
+	if queue == nil {
+		responses.RespNotFound(c)
+		return
+	}
+	if chat.ManualService.GetQueueTotalCount(admin.GetGroupId(), queue.Id) > 0 {
+		responses.RespValidateFail(c, "队列中还有等待的用户，无法删除")
+		return
+	}
+	_ = repositories.ChatQueueRepo.ReplaceAdmins(queue, []*models.Admin{})
+	repositories.ChatQueueRepo.Delete(queue)
+	responses.RespSuccess(c, gin.H{})
+}
+
+// 获取分组内的客服
+func (handler *ChatQueueHandler) getAdmins(gid int64, ids []int64) []*models.Admin {
+	if len(ids) == 0 {
+		return []*models.Admin{}
+	}
+	return repositories.AdminRepo.Get([]*repositories.Where{
+		{
+			Filed: "id in ?",
+			Value: ids,
+		},
+		{
+			Filed: "group_id = ?",
+			Value: gid,
+		},
+	}, -1, []string{}, []string{})
+}
@@ -1,7 +1,6 @@
 package admin
 
 import (
-	"time"
 	"ws/app/chat"
 	"ws/app/http/requests"
 	"ws/app/http/responses"
@@ -18,6 +17,10 @@ type ChatSessionHandler struct {
 }
 
 var sessionFilter = map[string]interface{}{
+	"cancel_reason": "=",
+	"close_reason":  "=",
+	"wrap_up_id":    "=",
+	"rating":        "=",
 	"admin_name": func(val string) *repositories.Where {
 		admins := repositories.AdminRepo.Get([]*repositories.Where{
 			{
@@ -85,7 +88,7 @@ func (handler *ChatSessionHandler) Index(c *gin.Context) {
 			})
 		}
 	}
-	p := repositories.ChatSessionRepo.Paginate(c, wheres, []string{"Admin", "User"}, []string{"id desc"})
+	p := repositories.ChatSessionRepo.Paginate(c, wheres, []string{"Admin", "User", "WrapUp"}, []string{"id desc"})
 	_ = p.DataFormat(func(item *models.ChatSession) interface{} {
 		return item.ToJson()
 	})
@@ -115,9 +118,7 @@ func (handler *ChatSessionHandler) Cancel(c *gin.Context) {
 		responses.RespFail(c, "会话已取消，请勿重复取消", 500)
 		return
 	}
-	session.CanceledAt = time.Now().Unix()
-	repositories.ChatSessionRepo.Save(session)
-	_ = chat.ManualService.Remove(session.UserId, session.GetUser().GetGroupId())
+	chat.SessionService.Cancel(session, models.CancelReasonAdmin)
 	websocket.AdminManager.BroadcastWaitingUser(session.GetUser().GetGroupId())
 	responses.RespSuccess(c, gin.H{})
 }
@@ -134,7 +135,11 @@ func (handler *ChatSessionHandler) Show(c *gin.Context) {
 			Filed: "group_id = ?",
 			Value: requests.GetAdmin(c).GetGroupId(),
 		},
-	}, []string{})
+	}, []string{"WrapUp"})
+	if session == nil {
+		responses.RespNotFound(c)
+		return
+	}
 	messages := repositories.MessageRepo.Get([]*repositories.Where{
 		{
 			Filed: "session_id = ?",
@@ -148,7 +153,7 @@ func (handler *ChatSessionHandler) Show(c *gin.Context) {
 			Filed: "source in ?",
 			Value: []int{models.SourceAdmin, models.SourceUser},
 		},
-	}, -1, []string{"User", "Admin"}, []string{"id desc"})
+	}, -1, []string{"User", "Admin", "Reply"}, []string{"id desc"})
 	data := make([]*resource.Message, 0, 0)
 	for _, msg := range messages {
 		data = append(data, msg.ToJson())
@@ -157,5 +162,6 @@ func (handler *ChatSessionHandler) Show(c *gin.Context) {
 		"messages": data,
 		"total":    len(data),
 		"session":  session.ToJson(),
+		"notes":    toNotesJson(repositories.NoteRepo.GetBySession(session)),
 	})
 }
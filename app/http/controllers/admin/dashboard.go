@@ -28,10 +28,15 @@ func (handler *DashboardHandler) GetOnlineAdmins(c *gin.Context) {
 			Value: ids,
 		},
 	}, -1, []string{}, []string{})
+	// 隐身的客服不显示
+	users = slice.Filter(users, func(index int, s *models.Admin) bool {
+		return isVisibleTo(user, s)
+	})
 	res := slice.Map(users, func(index int, s *models.Admin) gin.H {
 		return gin.H{
 			"username": s.Username,
 			"id":       s.ID,
+			"status":   s.GetSetting().GetStatus(),
 		}
 	})
 	responses.RespSuccess(c, res)
@@ -141,3 +146,76 @@ func (handler *DashboardHandler) GetOnlineInfo(c *gin.Context) {
 		"waiting_user_count": len(chat.ManualService.GetAll(admin.GetGroupId())),
 	})
 }
+
+// GetSatisfaction 满意度统计，包含分组和每个客服的平均评分
+func (handler *DashboardHandler) GetSatisfaction(c *gin.Context) {
+	admin := requests.GetAdmin(c)
+	startTime := carbon.Now().SubDays(30).StartOfDay().ToTimestamp()
+	endTime := carbon.Now().EndOfDay().ToTimestamp()
+	ratedAtArr := c.QueryArray("rated_at")
+	if len(ratedAtArr) > 0 {
+		startTime = carbon.Parse(ratedAtArr[0]).ToTimestamp()
+		if len(ratedAtArr) > 1 {
+			endTime = carbon.Parse(ratedAtArr[1]).ToTimestamp()
+		}
+	}
+	type satisfaction struct {
+		AdminId int64   `json:"admin_id"`
+		Count   int64   `json:"count"`
+		Average float64 `json:"average"`
+	}
+	query := func() *gorm.DB {
+		return databases.Db.Model(&models.ChatSession{}).
+			Where("group_id = ?", admin.GetGroupId()).
+			Where("rating > ?", 0).
+			Where("rated_at >= ?", startTime).
+			Where("rated_at <= ?", endTime)
+	}
+	group := &satisfaction{}
+	query().Select("count(*) as count, coalesce(avg(rating), 0) as average").Scan(group)
+	distribution := make([]map[string]interface{}, 0, 5)
+	for rating := 1; rating <= 5; rating++ {
+		var count int64
+		query().Where("rating = ?", rating).Count(&count)
+		distribution = append(distribution, map[string]interface{}{
+			"rating": rating,
+			"count":  count,
+		})
+	}
+	items := make([]*satisfaction, 0)
+	query().Select("admin_id, count(*) as count, avg(rating) as average").
+		Group("admin_id").
+		Scan(&items)
+	ids := slice.Map(items, func(index int, item *satisfaction) int64 {
+		return item.AdminId
+	})
+	names := make(map[int64]string, len(ids))
+	if len(ids) > 0 {
+		admins := repositories.AdminRepo.Get([]*repositories.Where{
+			{
+				Filed: "id in ?",
+				Value: ids,
+			},
+		}, -1, []string{}, []string{})
+		for _, a := range admins {
+			names[a.GetPrimaryKey()] = a.GetUsername()
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Average > items[j].Average
+	})
+	adminsResp := slice.Map(items, func(index int, item *satisfaction) gin.H {
+		return gin.H{
+			"admin_id":   item.AdminId,
+			"admin_name": names[item.AdminId],
+			"count":      item.Count,
+			"average":    item.Average,
+		}
+	})
+	responses.RespSuccess(c, gin.H{
+		"count":        group.Count,
+		"average":      group.Average,
+		"distribution": distribution,
+		"admins":       adminsResp,
+	})
+}
@@ -0,0 +1,125 @@
+package admin
+
+import (
+	"ws/app/chat"
+	"ws/app/http/requests"
+	"ws/app/http/responses"
+	"ws/app/models"
+	"ws/app/repositories"
+	"ws/app/resource"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HolidayHandler struct {
+}
+
+func (handler *HolidayHandler) Index(c *gin.Context) {
+	holidays := repositories.HolidayRepo.Get([]*repositories.Where{
+		{
+			Filed: "group_id = ?",
+			Value: requests.GetAdmin(c).GetGroupId(),
+		},
+	}, -1, []string{}, []string{"date desc"})
+	data := make([]*resource.Holiday, 0, len(holidays))
+	for _, holiday := range holidays {
+		data = append(data, holiday.ToJson())
+	}
+	responses.RespSuccess(c, data)
+}
+
+func (handler *HolidayHandler) Store(c *gin.Context) {
+	form := requests.HolidayForm{}
+	err := c.ShouldBind(&form)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	gid := requests.GetAdmin(c).GetGroupId()
+	if handler.firstByDate(gid, form.Date) != nil {
+		responses.RespValidateFail(c, "该日期已设置")
+		return
+	}
+	holiday := &models.Holiday{
+		GroupId: gid,
+	}
+	handler.fill(holiday, form)
+	repositories.HolidayRepo.Save(holiday)
+	chat.BusinessHoursService.Forget(gid)
+	responses.RespSuccess(c, holiday.ToJson())
+}
+
+func (handler *HolidayHandler) Update(c *gin.Context) {
+	gid := requests.GetAdmin(c).GetGroupId()
+	holiday := handler.first(c)
+	if holiday == nil {
+		responses.RespNotFound(c)
+		return
+	}
+	form := requests.HolidayForm{}
+	err := c.ShouldBind(&form)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	exist := handler.firstByDate(gid, form.Date)
+	if exist != nil && exist.Id != holiday.Id {
+		responses.RespValidateFail(c, "该日期已设置")
+		return
+	}
+	handler.fill(holiday, form)
+	repositories.HolidayRepo.Save(holiday)
+	chat.BusinessHoursService.Forget(gid)
+	responses.RespSuccess(c, holiday.ToJson())
+}
+
+func (handler *HolidayHandler) Delete(c *gin.Context) {
+	holiday := handler.first(c)
+	if holiday == nil {
+		responses.RespNotFound(c)
+		return
+	}
+	repositories.HolidayRepo.Delete(holiday)
+	chat.BusinessHoursService.Forget(holiday.GroupId)
+	responses.RespSuccess(c, gin.H{})
+}
+
+func (handler *HolidayHandler) first(c *gin.Context) *models.Holiday {
+	return repositories.HolidayRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: c.Param("id"),
+		},
+		{
+			Filed: "group_id = ?",
+			Value: requests.GetAdmin(c).GetGroupId(),
+		},
+	}, []string{})
+}
+
+func (handler *HolidayHandler) firstByDate(gid int64, date string) *models.Holiday {
+	return repositories.HolidayRepo.First([]*repositories.Where{
+		{
+			Filed: "group_id = ?",
+			Value: gid,
+		},
+		{
+			Filed: "date = ?",
+			Value: date,
+		},
+	}, []string{})
+}
+
+// 休息日不需要营业时间段
+func (handler *HolidayHandler) fill(holiday *models.Holiday, form requests.HolidayForm) {
+	holiday.Date = form.Date
+	holiday.Title = form.Title
+	holiday.IsOpen = form.IsOpen
+	if form.IsOpen {
+		holiday.StartTime = form.StartTime
+		holiday.EndTime = form.EndTime
+	} else {
+		holiday.StartTime = ""
+		holiday.EndTime = ""
+	}
+}
@@ -2,9 +2,11 @@ package admin
 
 import (
 	"fmt"
+	"strings"
 	"ws/app/file"
 	"ws/app/http/requests"
 	"ws/app/http/responses"
+	"ws/app/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,17 +14,50 @@ import (
 type ImageHandler struct {
 }
 
+// File 上传聊天附件(文件、语音、视频)
+func (handle *ImageHandler) File(c *gin.Context) {
+	f, err := c.FormFile("file")
+	if err != nil {
+		responses.RespValidateFail(c, "invalid file")
+		return
+	}
+	if f.Size > models.MaxFileSize {
+		responses.RespValidateFail(c, "文件大小超出限制")
+		return
+	}
+	admin := requests.GetAdmin(c)
+	ff, err := file.SaveAttachment(f, admin.GetGroupId())
+	if err == file.ErrTypeNotAllowed {
+		responses.RespValidateFail(c, err.Error())
+	} else if err != nil {
+		responses.RespFail(c, err.Error(), 500)
+	} else {
+		responses.RespSuccess(c, gin.H{
+			"url":  ff.FullUrl,
+			"name": ff.Name,
+			"size": ff.Size,
+			"mime": ff.Mime,
+		})
+	}
+}
+
 func (handle *ImageHandler) Store(c *gin.Context) {
-	f, _ := c.FormFile("file")
+	f, err := c.FormFile("file")
+	if err != nil {
+		responses.RespValidateFail(c, "invalid file")
+		return
+	}
 	admin := requests.GetAdmin(c)
 	path := c.Query("path")
-	if path == "" {
+	if path == "" || strings.Contains(path, "..") {
 		responses.RespValidateFail(c, "invalid path")
 		return
 	}
 	prefix := fmt.Sprintf("chat/%d/", admin.GetGroupId())
-	ff, err := file.Save(f, prefix+path)
-	if err != nil {
+	ff, err := file.SaveImage(f, prefix+path)
+	if err == file.ErrTypeNotAllowed {
+		responses.RespValidateFail(c, err.Error())
+	} else if err != nil {
 		responses.RespFail(c, err.Error(), 500)
 	} else {
 		responses.RespSuccess(c, gin.H{
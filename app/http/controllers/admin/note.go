@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"strconv"
+	"time"
+	"ws/app/http/requests"
+	"ws/app/http/responses"
+	"ws/app/http/websocket"
+	"ws/app/models"
+	"ws/app/repositories"
+	"ws/app/resource"
+
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/gin-gonic/gin"
+)
+
+type NoteHandler struct {
+}
+
+// Index 用户的内部备注，指定session_id时只获取该会话和用户的备注
+func (handler *NoteHandler) Index(c *gin.Context) {
+	admin := requests.GetAdmin(c)
+	uid, _ := strconv.ParseInt(c.Query("user_id"), 10, 64)
+	user := repositories.UserRepo.FirstById(uid)
+	if user == nil || !admin.AccessTo(user) {
+		responses.RespNotFound(c)
+		return
+	}
+	var notes []*models.Note
+	sessionId, _ := strconv.ParseUint(c.Query("session_id"), 10, 64)
+	if sessionId > 0 {
+		notes = repositories.NoteRepo.GetBySession(&models.ChatSession{
+			Id:     sessionId,
+			UserId: user.GetPrimaryKey(),
+		})
+	} else {
+		notes = repositories.NoteRepo.GetByUser(user.GetPrimaryKey())
+	}
+	responses.RespSuccess(c, toNotesJson(notes))
+}
+
+func (handler *NoteHandler) Store(c *gin.Context) {
+	form := requests.NoteForm{}
+	err := c.ShouldBind(&form)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	admin := requests.GetAdmin(c)
+	user := repositories.UserRepo.FirstById(form.UserId)
+	if user == nil || !admin.AccessTo(user) {
+		responses.RespValidateFail(c, "用户不存在")
+		return
+	}
+	if form.SessionId > 0 {
+		session := repositories.ChatSessionRepo.FirstById(form.SessionId)
+		if session == nil || session.UserId != user.GetPrimaryKey() {
+			responses.RespValidateFail(c, "会话不存在")
+			return
+		}
+	}
+	now := time.Now().Unix()
+	note := &models.Note{
+		GroupId:   admin.GetGroupId(),
+		UserId:    user.GetPrimaryKey(),
+		SessionId: form.SessionId,
+		AdminId:   admin.GetPrimaryKey(),
+		Content:   form.Content,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	repositories.NoteRepo.Save(note)
+	note.Admin, _ = admin.(*models.Admin)
+	websocket.AdminManager.BroadcastNote(note)
+	responses.RespSuccess(c, note.ToJson())
+}
+
+// Update 只能修改自己的备注
+func (handler *NoteHandler) Update(c *gin.Context) {
+	note := handler.firstByAuthor(c)
+	if note == nil {
+		responses.RespNotFound(c)
+		return
+	}
+	form := struct {
+		Content string `json:"content" binding:"required,max=1024"`
+	}{}
+	err := c.ShouldBind(&form)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	note.Content = form.Content
+	note.UpdatedAt = time.Now().Unix()
+	repositories.NoteRepo.Save(note)
+	responses.RespSuccess(c, note.ToJson())
+}
+
+// Delete 只能删除自己的备注
+func (handler *NoteHandler) Delete(c *gin.Context) {
+	note := handler.firstByAuthor(c)
+	if note == nil {
+		responses.RespNotFound(c)
+		return
+	}
+	repositories.NoteRepo.Delete(note)
+	responses.RespSuccess(c, gin.H{})
+}
+
+func (handler *NoteHandler) firstByAuthor(c *gin.Context) *models.Note {
+	admin := requests.GetAdmin(c)
+	return repositories.NoteRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: c.Param("id"),
+		},
+		{
+			Filed: "group_id = ?",
+			Value: admin.GetGroupId(),
+		},
+		{
+			Filed: "admin_id = ?",
+			Value: admin.GetPrimaryKey(),
+		},
+	}, []string{})
+}
+
+func toNotesJson(notes []*models.Note) []*resource.Note {
+	return slice.Map(notes, func(index int, note *models.Note) *resource.Note {
+		return note.ToJson()
+	})
+}
@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"time"
+	"ws/app/http/requests"
+	"ws/app/http/responses"
+	"ws/app/models"
+	"ws/app/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TicketHandler struct {
+}
+
+func (handler *TicketHandler) Index(c *gin.Context) {
+	wheres := requests.GetFilterWhere(c, map[string]interface{}{})
+	wheres = append(wheres, &repositories.Where{
+		Filed: "group_id = ?",
+		Value: requests.GetAdmin(c).GetGroupId(),
+	})
+	switch c.Query("status") {
+	case "pending":
+		wheres = append(wheres, &repositories.Where{
+			Filed: "handled_at = ?",
+			Value: 0,
+		})
+	case "handled":
+		wheres = append(wheres, &repositories.Where{
+			Filed: "handled_at > ?",
+			Value: 0,
+		})
+	}
+	p := repositories.TicketRepo.Paginate(c, wheres, []string{"User", "Admin"}, []string{"id desc"})
+	_ = p.DataFormat(func(item *models.Ticket) interface{} {
+		return item.ToJson()
+	})
+	responses.RespPagination(c, p)
+}
+
+// Handle 标记留言工单已处理
+func (handler *TicketHandler) Handle(c *gin.Context) {
+	admin := requests.GetAdmin(c)
+	ticket := repositories.TicketRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: c.Param("id"),
+		},
+		{
+			Filed: "group_id = ?",
+			Value: admin.GetGroupId(),
+		},
+	}, []string{})
+	if ticket == nil {
+		responses.RespNotFound(c)
+		return
+	}
+	if ticket.HandledAt > 0 {
+		responses.RespValidateFail(c, "工单已处理")
+		return
+	}
+	ticket.HandledAt = time.Now().Unix()
+	ticket.AdminId = admin.GetPrimaryKey()
+	repositories.TicketRepo.Save(ticket)
+	responses.RespSuccess(c, gin.H{})
+}
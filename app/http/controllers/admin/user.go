@@ -41,8 +41,24 @@ func (User *UserHandler) UpdateSetting(c *gin.Context) {
 	setting.WelcomeContent = form.WelcomeContent
 	setting.OfflineContent = form.OfflineContent
 	setting.Name = form.Name
+	setting.MaxChats = form.MaxChats
 	repositories.AdminRepo.SaveSetting(setting)
 	websocket.AdminManager.NoticeUpdateSetting(admin)
+	go websocket.AdminManager.AutoAssign(admin.GetGroupId())
+	responses.RespSuccess(c, gin.H{})
+}
+
+// UpdateStatus 修改在线状态
+func (User *UserHandler) UpdateStatus(c *gin.Context) {
+	u := requests.GetAdmin(c)
+	admin := u.(*models.Admin)
+	form := requests.AdminStatusForm{}
+	err := c.ShouldBind(&form)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	websocket.AdminManager.SetStatus(admin, form.Status, false)
 	responses.RespSuccess(c, gin.H{})
 }
 
@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"ws/app/http/requests"
+	"ws/app/http/responses"
+	"ws/app/models"
+	"ws/app/repositories"
+	"ws/app/resource"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WrapUpCodeHandler struct {
+}
+
+func (handler *WrapUpCodeHandler) Index(c *gin.Context) {
+	codes := repositories.WrapUpCodeRepo.Get([]*repositories.Where{
+		{
+			Filed: "group_id = ?",
+			Value: requests.GetAdmin(c).GetGroupId(),
+		},
+	}, -1, []string{}, []string{"id"})
+	data := make([]*resource.WrapUpCode, 0, len(codes))
+	for _, code := range codes {
+		data = append(data, code.ToJson())
+	}
+	responses.RespSuccess(c, data)
+}
+
+// Options 结束会话时可选择的小结分类
+func (handler *WrapUpCodeHandler) Options(c *gin.Context) {
+	codes := repositories.WrapUpCodeRepo.Get([]*repositories.Where{
+		{
+			Filed: "group_id = ?",
+			Value: requests.GetAdmin(c).GetGroupId(),
+		},
+	}, -1, []string{}, []string{"id"})
+	options := make([]resource.Options, 0, len(codes))
+	for _, code := range codes {
+		options = append(options, resource.Options{
+			Value: code.Id,
+			Label: code.Title,
+		})
+	}
+	responses.RespSuccess(c, options)
+}
+
+func (handler *WrapUpCodeHandler) Store(c *gin.Context) {
+	form := requests.WrapUpCodeForm{}
+	err := c.ShouldBind(&form)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	gid := requests.GetAdmin(c).GetGroupId()
+	if repositories.WrapUpCodeRepo.FirstByName(gid, form.Name) != nil {
+		responses.RespValidateFail(c, "已存在同名的小结分类")
+		return
+	}
+	code := &models.WrapUpCode{
+		GroupId: gid,
+		Name:    form.Name,
+		Title:   form.Title,
+	}
+	repositories.WrapUpCodeRepo.Save(code)
+	responses.RespSuccess(c, code.ToJson())
+}
+
+func (handler *WrapUpCodeHandler) Update(c *gin.Context) {
+	gid := requests.GetAdmin(c).GetGroupId()
+	code := repositories.WrapUpCodeRepo.FirstByGroup(gid, c.Param("id"))
+	if code == nil {
+		responses.RespNotFound(c)
+		return
+	}
+	form := requests.WrapUpCodeForm{}
+	err := c.ShouldBind(&form)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	exist := repositories.WrapUpCodeRepo.FirstByName(gid, form.Name)
+	if exist != nil && exist.Id != code.Id {
+		responses.RespValidateFail(c, "已存在同名的其他小结分类")
+		return
+	}
+	code.Name = form.Name
+	code.Title = form.Title
+	repositories.WrapUpCodeRepo.Save(code)
+	responses.RespSuccess(c, code.ToJson())
+}
+
+// Delete 删除小结分类，已使用该分类的会话保留分类id
+func (handler *WrapUpCodeHandler) Delete(c *gin.Context) {
+	code := repositories.WrapUpCodeRepo.FirstByGroup(requests.GetAdmin(c).GetGroupId(), c.Param("id"))
+	if code == nil {
+		responses.RespNotFound(c)
+		return
+	}
+	repositories.WrapUpCodeRepo.Delete(code)
+	responses.RespSuccess(c, gin.H{})
+}
@@ -31,9 +31,11 @@ func Index(c *gin.Context) {
 	}
 
 	c.HTML(http.StatusOK, "monitor.tmpl", gin.H{
-		"admin":     adminCount,
-		"user":      userCount,
-		"isCluster": isCluster,
-		"server":    serverStr,
+		"admin":        adminCount,
+		"user":         userCount,
+		"isCluster":    isCluster,
+		"server":       serverStr,
+		"adminMetrics": websocket.AdminManager.GetMetrics().Snapshot(),
+		"userMetrics":  websocket.UserManager.GetMetrics().Snapshot(),
 	})
 }
@@ -4,12 +4,14 @@ import (
 	"github.com/duke-git/lancet/v2/random"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
+	"net/http"
 	"strconv"
 	"time"
 	"ws/app/chat"
 	"ws/app/file"
 	"ws/app/http/requests"
 	"ws/app/http/responses"
+	"ws/app/http/websocket"
 	"ws/app/models"
 	"ws/app/repositories"
 	"ws/app/resource"
@@ -24,23 +26,66 @@ func ReadAll(c *gin.Context) {
 		responses.RespValidateFail(c, err)
 		return
 	}
-	wheres := []*repositories.Where{
-		{
-			Filed: "id <= ?",
-			Value: form.MsgId,
-		},
-		{
-			Filed: "user_id = ?",
-			Value: requests.GetUser(c).GetPrimaryKey(),
-		},
-		{
-			Filed: "is_read = ?",
-			Value: 0,
-		},
+	user := requests.GetUser(c)
+	msgId := repositories.MessageRepo.ReadByUser(user.GetPrimaryKey(), form.MsgId)
+	if msgId > 0 {
+		adminId := chat.UserService.GetValidAdmin(user.GetPrimaryKey())
+		if adminId > 0 {
+			go websocket.AdminManager.DeliveryRead(user.GetPrimaryKey(), adminId, msgId, false)
+		}
+	}
+	responses.RespSuccess(c, gin.H{})
+}
+
+// RecallMessage 撤回消息
+func RecallMessage(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		responses.RespValidateFail(c, "invalid params")
+		return
+	}
+	user := requests.GetUser(c)
+	msg, err := websocket.UserManager.RecallMessage(user.GetPrimaryKey(), id)
+	if err != nil {
+		responses.RespFail(c, err.Error(), 500)
+		return
+	}
+	responses.RespSuccess(c, msg.ToJson())
+}
+
+// SetQueue 指定转人工时进入的队列，name为空则进入默认队列
+func SetQueue(c *gin.Context) {
+	form := &struct {
+		Queue string `json:"queue" form:"queue" binding:"max=32"`
+	}{}
+	err := c.ShouldBind(form)
+	if err != nil {
+		responses.RespValidateFail(c, "invalid params")
+		return
+	}
+	user := requests.GetUser(c)
+	err = websocket.UserManager.SetQueue(user, form.Queue)
+	if err != nil {
+		responses.RespValidateFail(c, err.Error())
+		return
+	}
+	responses.RespSuccess(c, gin.H{})
+}
+
+// SseMessage sse连接提交消息，消息格式与websocket一致
+func SseMessage(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, websocket.MaxMessageSize)
+	body, err := c.GetRawData()
+	if err != nil {
+		responses.RespValidateFail(c, "invalid params")
+		return
+	}
+	user := requests.GetUser(c)
+	err = websocket.UserManager.ReceiveSseMessage(user, c.Param("uuid"), body, false)
+	if err != nil {
+		responses.RespFail(c, err.Error(), 500)
+		return
 	}
-	repositories.MessageRepo.Update(wheres, map[string]interface{}{
-		"is_read": 1,
-	})
 	responses.RespSuccess(c, gin.H{})
 }
 
@@ -71,7 +116,7 @@ func GetHistoryMessage(c *gin.Context) {
 			size = sizeInt
 		}
 	}
-	messages := repositories.MessageRepo.Get(wheres, size, []string{"Admin", "User"}, []string{"id desc"})
+	messages := repositories.MessageRepo.Get(wheres, size, []string{"Admin", "User", "Reply"}, []string{"id desc"})
 	messagesResources := make([]*resource.Message, len(messages), len(messages))
 	messageIds := make([]int64, len(messages), len(messages))
 	for index, m := range messages {
@@ -125,11 +170,44 @@ func Subscribe(c *gin.Context) {
 	responses.RespSuccess(c, gin.H{})
 }
 
+// File 上传聊天附件(文件、语音、视频)
+func File(c *gin.Context) {
+	f, err := c.FormFile("file")
+	if err != nil {
+		responses.RespValidateFail(c, "invalid file")
+		return
+	}
+	if f.Size > models.MaxFileSize {
+		responses.RespValidateFail(c, "文件大小超出限制")
+		return
+	}
+	user := requests.GetUser(c)
+	ff, err := file.SaveAttachment(f, user.GetGroupId())
+	if err == file.ErrTypeNotAllowed {
+		responses.RespValidateFail(c, err.Error())
+	} else if err != nil {
+		responses.RespFail(c, err.Error(), 500)
+	} else {
+		responses.RespSuccess(c, gin.H{
+			"url":  ff.FullUrl,
+			"name": ff.Name,
+			"size": ff.Size,
+			"mime": ff.Mime,
+		})
+	}
+}
+
 // Image 聊天图片
 func Image(c *gin.Context) {
-	f, _ := c.FormFile("file")
-	ff, err := file.Save(f, "chat")
+	f, err := c.FormFile("file")
 	if err != nil {
+		responses.RespValidateFail(c, "invalid file")
+		return
+	}
+	ff, err := file.SaveImage(f, "chat")
+	if err == file.ErrTypeNotAllowed {
+		responses.RespValidateFail(c, err.Error())
+	} else if err != nil {
 		responses.RespFail(c, err.Error(), 500)
 	} else {
 		responses.RespSuccess(c, gin.H{
@@ -1,11 +1,14 @@
 package requests
 
+import "ws/app/models"
+
 type AutoMessageForm struct {
-	Name    string `json:"name" form:"name" binding:"required,max=32"`
-	Type    string `json:"type" form:"type" binding:"required,autoMessageType"`
-	Content string `json:"content" form:"content" binding:"required,max=512"`
-	Title   string `json:"title" form:"title" binding:"max=32"`
-	Url     string `json:"url" form:"url" binding:"max=512"`
+	Name    string           `json:"name" form:"name" binding:"required,max=32"`
+	Type    string           `json:"type" form:"type" binding:"required,autoMessageType"`
+	Content string           `json:"content" form:"content" binding:"required,max=512"`
+	Title   string           `json:"title" form:"title" binding:"max=32"`
+	Url     string           `json:"url" form:"url" binding:"max=512"`
+	Buttons []*models.Button `json:"buttons" form:"buttons"`
 }
 
 type AutoRuleForm struct {
@@ -20,12 +23,63 @@ type AutoRuleForm struct {
 	Scenes    []string `json:"scenes" form:"scenes"`
 }
 
+type ChatQueueForm struct {
+	Name     string  `json:"name" binding:"required,max=32"`
+	Title    string  `json:"title" binding:"required,max=64"`
+	AdminIds []int64 `json:"admin_ids"`
+}
+
+type BusinessHourItem struct {
+	Weekday   int    `json:"weekday" binding:"min=0,max=6"`
+	StartTime string `json:"start_time" binding:"required,clock"`
+	EndTime   string `json:"end_time" binding:"required,clockAfter"`
+}
+
+type BusinessHoursForm struct {
+	Timezone string              `json:"timezone" binding:"required,timezone"`
+	Hours    []*BusinessHourItem `json:"hours" binding:"max=50,dive"`
+}
+
+type HolidayForm struct {
+	Date      string `json:"date" binding:"required,datetime=2006-01-02"`
+	Title     string `json:"title" binding:"required,max=32"`
+	IsOpen    bool   `json:"is_open"`
+	StartTime string `json:"start_time" binding:"required_if=IsOpen true,omitempty,clock"`
+	EndTime   string `json:"end_time" binding:"required_if=IsOpen true,omitempty,clockAfter"`
+}
+
+type WrapUpCodeForm struct {
+	Name  string `json:"name" binding:"required,max=32"`
+	Title string `json:"title" binding:"required,max=64"`
+}
+
+// WrapUpForm 客服结束会话时填写的小结
+type WrapUpForm struct {
+	WrapUpId int64  `json:"wrap_up_id" binding:"min=0"`
+	Remark   string `json:"remark" binding:"max=255"`
+}
+
+// UserPriorityForm 用户排队优先级，0普通 1vip 2svip
+type UserPriorityForm struct {
+	Priority int8 `json:"priority" binding:"min=0,max=2"`
+}
+
+type NoteForm struct {
+	UserId    int64  `json:"user_id" binding:"required"`
+	SessionId uint64 `json:"session_id"`
+	Content   string `json:"content" binding:"required,max=1024"`
+}
+
 type AdminChatSettingForm struct {
 	Background     string `json:"background" binding:"max=512"`
 	IsAutoAccept   bool   `json:"is_auto_accept"`
 	WelcomeContent string `json:"welcome_content" binding:"max=512"`
 	OfflineContent string `json:"offline_content" binding:"max=512"`
 	Name           string `json:"name" binding:"max=20"`
+	MaxChats       int    `json:"max_chats" binding:"min=0,max=100"`
+}
+type AdminStatusForm struct {
+	Status string `json:"status" binding:"required,oneof=online busy away invisible"`
 }
 type LoginForm struct {
 	Username string `form:"username" json:"username" binding:"required"`
@@ -3,6 +3,8 @@ package requests
 import (
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"regexp"
+	"unicode/utf8"
 	"ws/app/databases"
 	"ws/app/models"
 )
@@ -12,10 +14,36 @@ func init() {
 	if ok {
 		_ = v.RegisterValidation("autoMessageType", autoMessageTypeValidator)
 		_ = v.RegisterValidation("autoRule", autoRuleValidator)
+		_ = v.RegisterValidation("clock", clockValidator)
+		_ = v.RegisterValidation("clockAfter", clockAfterValidator)
 	}
 }
 
+// 时间格式为15:04，24:00表示当天结束
+var clockRegexp = regexp.MustCompile(`^(([01][0-9]|2[0-3]):[0-5][0-9]|24:00)$`)
+
+func clockValidator(fl validator.FieldLevel) bool {
+	return clockRegexp.MatchString(fl.Field().String())
+}
+
+// 结束时间必须晚于同一结构体中的StartTime
+func clockAfterValidator(fl validator.FieldLevel) bool {
+	end := fl.Field().String()
+	if !clockRegexp.MatchString(end) {
+		return false
+	}
+	start := fl.Parent().FieldByName("StartTime")
+	if !start.IsValid() {
+		return false
+	}
+	return end > start.String()
+}
+
 func autoMessageTypeValidator(fl validator.FieldLevel) bool {
+	if fl.Field().String() == models.TypeButtons {
+		form, _ := fl.Parent().Interface().(AutoMessageForm)
+		return buttonsValidator(form.Buttons)
+	}
 	if fl.Field().String() == models.TypeText ||
 		fl.Field().String() == models.TypeNavigate ||
 		fl.Field().String() == models.TypeImage {
@@ -31,6 +59,31 @@ func autoMessageTypeValidator(fl validator.FieldLevel) bool {
 	return false
 }
 
+// 快捷按钮验证，按钮的value不能重复
+func buttonsValidator(buttons []*models.Button) bool {
+	if len(buttons) == 0 || len(buttons) > models.MaxButtons {
+		return false
+	}
+	values := make(map[string]struct{}, len(buttons))
+	for _, button := range buttons {
+		if button == nil {
+			return false
+		}
+		labelLength := utf8.RuneCountInString(button.Label)
+		if labelLength == 0 || labelLength > 20 {
+			return false
+		}
+		if button.Value == "" || utf8.RuneCountInString(button.Value) > 32 {
+			return false
+		}
+		if _, exist := values[button.Value]; exist {
+			return false
+		}
+		values[button.Value] = struct{}{}
+	}
+	return true
+}
+
 func autoRuleValidator(fl validator.FieldLevel) bool {
 	parent := fl.Parent()
 	form, ok := parent.Interface().(AutoRuleForm)
@@ -43,7 +96,8 @@ func autoRuleValidator(fl validator.FieldLevel) bool {
 	for _, name := range form.Scenes {
 		if name != models.SceneNotAccepted &&
 			name != models.SceneAdminOnline &&
-			name != models.SceneAdminOffline {
+			name != models.SceneAdminOffline &&
+			name != models.SceneClosed {
 			return false
 		}
 	}
@@ -61,7 +115,7 @@ func autoRuleValidator(fl validator.FieldLevel) bool {
 		}
 	}
 	if form.ReplyType == models.ReplyTypeEvent{
-		if form.Key != models.EventBreak {
+		if form.Key != models.EventBreak && form.Key != models.EventTicket {
 			return  false
 		}
 	}
@@ -34,6 +34,11 @@ func RespError(c *gin.Context, msg interface{}) {
 		"message": msg,
 	})
 }
+func RespUnavailable(c *gin.Context, msg interface{}) {
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"message": msg,
+	})
+}
 func RespNotFound(c *gin.Context) {
 	c.JSON(http.StatusNotFound, "404 not found")
 }
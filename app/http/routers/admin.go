@@ -13,17 +13,23 @@ import (
 )
 
 var (
-	adminHandler       = &http.AdminsHandler{}
-	userHandler        = &http.UserHandler{}
-	chatHandler        = &http.ChatHandler{}
-	settingHandler     = &http.SettingHandler{}
-	autoMessageHandler = &http.AutoMessageHandler{}
-	autoRuleHandler    = &http.AutoRuleHandler{}
-	systemRuleHandler  = &http.SystemRuleHandler{}
-	chatSessionHandler = &http.ChatSessionHandler{}
-	dashboardHandler   = &http.DashboardHandler{}
-	transferHandler    = &http.TransferHandler{}
-	imageHandler       = &http.ImageHandler{}
+	adminHandler        = &http.AdminsHandler{}
+	userHandler         = &http.UserHandler{}
+	chatHandler         = &http.ChatHandler{}
+	settingHandler      = &http.SettingHandler{}
+	autoMessageHandler  = &http.AutoMessageHandler{}
+	autoRuleHandler     = &http.AutoRuleHandler{}
+	systemRuleHandler   = &http.SystemRuleHandler{}
+	chatSessionHandler  = &http.ChatSessionHandler{}
+	dashboardHandler    = &http.DashboardHandler{}
+	transferHandler     = &http.TransferHandler{}
+	imageHandler        = &http.ImageHandler{}
+	chatQueueHandler    = &http.ChatQueueHandler{}
+	businessHourHandler = &http.BusinessHourHandler{}
+	holidayHandler      = &http.HolidayHandler{}
+	ticketHandler       = &http.TicketHandler{}
+	wrapUpCodeHandler   = &http.WrapUpCodeHandler{}
+	noteHandler         = &http.NoteHandler{}
 )
 
 func registerAdmin() {
@@ -40,6 +46,7 @@ func registerAdmin() {
 	authGroup.POST("/me/avatar", userHandler.Avatar)
 	authGroup.GET("/me/settings", userHandler.Setting)
 	authGroup.PUT("/me/settings", userHandler.UpdateSetting)
+	authGroup.PUT("/me/status", userHandler.UpdateStatus)
 
 	authGroup.DELETE("/ws/chat-user/:id", chatHandler.RemoveUser)
 	authGroup.POST("/ws/req-id", chatHandler.GetReqId)
@@ -47,13 +54,18 @@ func registerAdmin() {
 	authGroup.GET("/ws/chat-users", chatHandler.ChatUserList)
 	authGroup.POST("/ws/read-all", chatHandler.ReadAll)
 	authGroup.GET("/ws/messages", chatHandler.GetHistoryMessage)
+	authGroup.POST("/ws/messages/:id/recall", chatHandler.RecallMessage)
+	authGroup.PUT("/ws/messages/:id", chatHandler.EditMessage)
+	authGroup.GET("/ws/messages/:id/revisions", chatHandler.MessageRevisions)
 	authGroup.GET("/ws/user/:id", chatHandler.GetUserInfo)
+	authGroup.PUT("/ws/user/:id/priority", chatHandler.UpdateUserPriority)
 	authGroup.GET("/ws/sessions/:uid", chatHandler.GetHistorySession)
 	authGroup.POST("/ws/transfer/:id/cancel", chatHandler.CancelTransfer)
 	authGroup.POST("/ws/transfer", chatHandler.Transfer)
 	authGroup.GET("/ws/transfer/:id/messages", chatHandler.TransferMessages)
 
 	authGroup.POST("/images", imageHandler.Store)
+	authGroup.POST("/ws/file", imageHandler.File)
 
 	authGroup.GET("/settings", settingHandler.Index)
 	authGroup.PUT("/settings/:id", settingHandler.Update)
@@ -70,6 +82,8 @@ func registerAdmin() {
 	authGroup.GET("/options/messages", autoRuleHandler.MessageOptions)
 	authGroup.GET("/options/scenes", autoRuleHandler.SceneOptions)
 	authGroup.GET("/options/events", autoRuleHandler.EventOptions)
+	authGroup.GET("/options/queues", chatQueueHandler.Options)
+	authGroup.GET("/options/wrap-up-codes", wrapUpCodeHandler.Options)
 
 	authGroup.POST("/auto-rules", autoRuleHandler.Store)
 	authGroup.PUT("/auto-rules/:id", autoRuleHandler.Update)
@@ -85,11 +99,42 @@ func registerAdmin() {
 	authGroup.GET("/dashboard/online-info", dashboardHandler.GetOnlineInfo)
 	authGroup.GET("/dashboard/online-users", dashboardHandler.GetOnlineUsers)
 	authGroup.GET("/dashboard/online-admins", dashboardHandler.GetOnlineAdmins)
+	authGroup.GET("/dashboard/satisfaction", dashboardHandler.GetSatisfaction)
+
+	authGroup.GET("/queues", chatQueueHandler.Index)
+	authGroup.POST("/queues", chatQueueHandler.Store)
+	authGroup.PUT("/queues/:id", chatQueueHandler.Update)
+	authGroup.DELETE("/queues/:id", chatQueueHandler.Delete)
+
+	authGroup.GET("/notes", noteHandler.Index)
+	authGroup.POST("/notes", noteHandler.Store)
+	authGroup.PUT("/notes/:id", noteHandler.Update)
+	authGroup.DELETE("/notes/:id", noteHandler.Delete)
+
+	authGroup.GET("/wrap-up-codes", wrapUpCodeHandler.Index)
+	authGroup.POST("/wrap-up-codes", wrapUpCodeHandler.Store)
+	authGroup.PUT("/wrap-up-codes/:id", wrapUpCodeHandler.Update)
+	authGroup.DELETE("/wrap-up-codes/:id", wrapUpCodeHandler.Delete)
+
+	authGroup.GET("/business-hours", businessHourHandler.Index)
+	authGroup.PUT("/business-hours", businessHourHandler.Update)
+
+	authGroup.GET("/holidays", holidayHandler.Index)
+	authGroup.POST("/holidays", holidayHandler.Store)
+	authGroup.PUT("/holidays/:id", holidayHandler.Update)
+	authGroup.DELETE("/holidays/:id", holidayHandler.Delete)
+
+	authGroup.GET("/tickets", ticketHandler.Index)
+	authGroup.POST("/tickets/:id/handle", ticketHandler.Handle)
 
 	authGroup.GET("/transfers", transferHandler.Index)
 	authGroup.POST("/transfers/:id/cancel", transferHandler.Cancel)
 
 	authGroup.GET("/ws", func(c *gin.Context) {
+		if websocket.AdminManager.IsDraining() {
+			responses.RespUnavailable(c, "server restarting")
+			return
+		}
 		u := requests.GetAdmin(c)
 		admin := u.(*models.Admin)
 		admin.GetSetting()
@@ -99,7 +144,7 @@ func registerAdmin() {
 			responses.RespError(c, err.Error())
 			return
 		}
-		client := websocket.NewConn(admin, conn, websocket.AdminManager)
+		client := websocket.NewConn(admin, conn, websocket.AdminManager, websocket.GetCodec(conn.Subprotocol()))
 		websocket.AdminManager.Register(client)
 	})
 }
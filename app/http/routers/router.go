@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 	"ws/app/http/controllers/monitor"
+	connection "ws/app/http/websocket"
 	"ws/config"
 
 	"github.com/gin-contrib/cors"
@@ -20,6 +21,7 @@ var (
 		CheckOrigin: func(r *http.Request) bool {
 			return true
 		},
+		Subprotocols: connection.Subprotocols,
 	}
 )
 
@@ -40,7 +42,9 @@ func Setup() {
 		AllowHeaders:     []string{"*"},
 		AllowCredentials: true,
 	}))
-	Router.Static("/assets", config.GetStoragePath()+"/assets")
+	assets := Router.Group("/assets")
+	assets.Use(attachmentHeader)
+	assets.Static("/", config.GetStoragePath()+"/assets")
 	Router.GET("/", func(c *gin.Context) {
 		c.JSON(200, "hello world")
 	})
@@ -52,3 +56,12 @@ func Setup() {
 	registerAdmin()
 	registerFrontend()
 }
+
+// 聊天附件一律以下载方式返回，避免用户上传的文件在本站域名下被浏览器直接渲染
+func attachmentHeader(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, "/assets/chat/files/") {
+		c.Header("Content-Disposition", "attachment")
+		c.Header("X-Content-Type-Options", "nosniff")
+	}
+	c.Next()
+}
@@ -3,6 +3,7 @@ package routers
 import (
 	http "ws/app/http/controllers/user"
 	middleware "ws/app/http/middleware/user"
+	"ws/app/http/responses"
 	"ws/app/http/websocket"
 	"ws/app/models"
 
@@ -19,17 +20,47 @@ func registerFrontend() {
 		auth.POST("/subscribe", http.Subscribe)
 		auth.GET("/ws/messages", http.GetHistoryMessage)
 		auth.POST("/ws/image", http.Image)
+		auth.POST("/ws/file", http.File)
 		auth.POST("/ws/req-id", http.GetReqId)
 		auth.POST("/ws/read", http.ReadAll)
+		auth.POST("/ws/queue", http.SetQueue)
+		auth.POST("/ws/messages/:id/recall", http.RecallMessage)
 		auth.GET("/ws", func(c *gin.Context) {
+			if websocket.UserManager.IsDraining() {
+				responses.RespUnavailable(c, "server restarting")
+				return
+			}
 			conn, err := upgrade.Upgrade(c.Writer, c.Request, nil)
 			if err != nil {
 				return
 			}
 			ui, _ := c.Get("frontend")
 			userModel := ui.(*models.User)
-			client := websocket.NewConn(userModel, conn, websocket.UserManager)
+			// 连接时可通过queue参数指定转人工的队列
+			if queue := c.Query("queue"); queue != "" {
+				_ = websocket.UserManager.SetQueue(userModel, queue)
+			}
+			client := websocket.NewConn(userModel, conn, websocket.UserManager, websocket.GetCodec(conn.Subprotocol()))
+			websocket.UserManager.Register(client)
+		})
+		// 无法使用websocket时的sse连接，上行消息通过POST提交
+		auth.GET("/ws/sse", func(c *gin.Context) {
+			if websocket.UserManager.IsDraining() {
+				responses.RespUnavailable(c, "server restarting")
+				return
+			}
+			ui, _ := c.Get("frontend")
+			userModel := ui.(*models.User)
+			if queue := c.Query("queue"); queue != "" {
+				_ = websocket.UserManager.SetQueue(userModel, queue)
+			}
+			client, transport, err := websocket.NewSseConn(userModel, c.Writer, websocket.UserManager)
+			if err != nil {
+				return
+			}
 			websocket.UserManager.Register(client)
+			transport.Wait(c.Request.Context())
 		})
+		auth.POST("/ws/sse/:uuid", http.SseMessage)
 	}
 }
@@ -11,7 +11,6 @@ import (
 
 const (
 	ReceiptAction        = "receipt"
-	PingAction           = "ping"
 	UserOnLineAction     = "user-online"
 	UserOffLineAction    = "user-offline"
 	WaitingUserAction    = "waiting-users"
@@ -23,8 +22,27 @@ const (
 	MoreThanOne          = "more-than-one"
 	UserTransfer         = "user-transfer"
 	ErrorMessage         = "error-message"
+	TypingStartAction    = "typing-start"
+	TypingStopAction     = "typing-stop"
+	MessageReadAction    = "message-read"
+	RecallMessageAction  = "recall-message"
+	MessageRecalled      = "message-recalled"
+	EditMessageAction    = "edit-message"
+	MessageEdited        = "message-edited"
+	AckAction            = "ack"
+	SyncAction           = "sync"
+	SyncResultAction     = "sync-result"
+	ServerRestarting     = "server-restarting"
+	ButtonClickAction    = "button-click"
+	UserAssigned         = "user-assigned"
+	QueueLocationAction  = "queue-location"
+	SurveyAction         = "survey"
+	NoteCreated          = "note-created"
 )
 
+// TypingTimeout 输入状态有效期(秒)，超过该时间未刷新则自动停止
+const TypingTimeout = 6
+
 type Action struct {
 	Data   interface{} `json:"data"`
 	Time   int64       `json:"time"`
@@ -40,28 +58,35 @@ func (action *Action) String() string {
 }
 
 func (action *Action) Marshal() (b []byte, err error) {
-	if action.Action == PingAction {
-		return []byte(""), nil
-	}
+	return action.Encode(jsonCodec{})
+}
+
+func (action *Action) UnMarshal(b []byte) (err error) {
+	return action.Decode(jsonCodec{}, b)
+}
+
+// Encode 使用指定的codec编码
+func (action *Action) Encode(codec Codec) (b []byte, err error) {
 	if action.Action == ReceiveMessageAction {
 		msg, ok := action.Data.(*models.Message)
 		if !ok {
 			err = errors.New("param error")
 			return
 		}
-		b, err = json.Marshal(Action{
+		b, err = codec.Marshal(Action{
 			Time:   action.Time,
 			Action: action.Action,
 			Data:   msg.ToJson(),
 		})
 		return
 	}
-	b, err = json.Marshal(action)
+	b, err = codec.Marshal(action)
 	return
 }
 
-func (action *Action) UnMarshal(b []byte) (err error) {
-	err = json.Unmarshal(b, action)
+// Decode 使用指定的codec解码
+func (action *Action) Decode(codec Codec, b []byte) (err error) {
+	err = codec.Unmarshal(b, action)
 	return
 }
 
@@ -70,12 +95,55 @@ func (action *Action) GetMessage() (message *models.Message, err error) {
 	if action.Action == SendMessageAction {
 		message = &models.Message{}
 		err = mapstructure.Decode(action.Data, message)
+		// payload类型的消息以摘要作为文本内容
+		if err == nil && models.IsPayloadType(message.Type) {
+			message.Content = message.Payload.GetSummary(message.Type)
+		}
 	} else {
 		err = errors.New("invalid action")
 	}
 	return
 
 }
+
+// GetInt 获取action data中的整数字段
+func (action *Action) GetInt(key string) int64 {
+	data, ok := action.Data.(map[string]interface{})
+	if ok {
+		// json解码为float64，msgpack解码为int64/uint64
+		switch id := data[key].(type) {
+		case float64:
+			return int64(id)
+		case int64:
+			return id
+		case uint64:
+			return int64(id)
+		}
+	}
+	return 0
+}
+
+// GetString 获取action data中的字符串字段
+func (action *Action) GetString(key string) string {
+	data, ok := action.Data.(map[string]interface{})
+	if ok {
+		if s, ok := data[key].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// GetUserId 获取action data中的user_id
+func (action *Action) GetUserId() int64 {
+	return action.GetInt("user_id")
+}
+
+// GetMsgId 获取action data中的msg_id
+func (action *Action) GetMsgId() int64 {
+	return action.GetInt("msg_id")
+}
+
 func NewReceiveAction(msg *models.Message) *Action {
 	return &Action{
 		Action: ReceiveMessageAction,
@@ -83,6 +151,18 @@ func NewReceiveAction(msg *models.Message) *Action {
 		Data:   msg,
 	}
 }
+
+// NewSyncResult 本次sync补发的结果，has_more为true时客户端以last_id继续sync
+func NewSyncResult(lastId int64, hasMore bool) *Action {
+	return &Action{
+		Action: SyncResultAction,
+		Time:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"last_id":  lastId,
+			"has_more": hasMore,
+		},
+	}
+}
 func NewReceiptAction(msg *models.Message) (act *Action) {
 	data := make(map[string]interface{})
 	data["user_id"] = msg.UserId
@@ -131,12 +211,6 @@ func NewOtherLogin() *Action {
 		Time:   time.Now().Unix(),
 	}
 }
-func NewPing() *Action {
-	return &Action{
-		Action: PingAction,
-		Time:   time.Now().Unix(),
-	}
-}
 func NewWaitingUsers(i interface{}) *Action {
 	return &Action{
 		Action: WaitingUserAction,
@@ -158,6 +232,33 @@ func NewUserTransfer(i interface{}) *Action {
 		Action: UserTransfer,
 	}
 }
+
+// NewQueueLocation 排队位置和预计等待时长
+func NewQueueLocation(location *resource.QueueLocation) *Action {
+	return &Action{
+		Data:   location,
+		Time:   time.Now().Unix(),
+		Action: QueueLocationAction,
+	}
+}
+
+// NewNoteCreated 其他客服新增了内部备注
+func NewNoteCreated(note *resource.Note) *Action {
+	return &Action{
+		Data:   note,
+		Time:   time.Now().Unix(),
+		Action: NoteCreated,
+	}
+}
+
+// NewUserAssigned 用户被自动分配给客服
+func NewUserAssigned(user *resource.User) *Action {
+	return &Action{
+		Data:   user,
+		Time:   time.Now().Unix(),
+		Action: UserAssigned,
+	}
+}
 func NewErrorMessage(msg string) *Action {
 	return &Action{
 		Data:   msg,
@@ -165,3 +266,62 @@ func NewErrorMessage(msg string) *Action {
 		Action: ErrorMessage,
 	}
 }
+
+// NewServerRestarting 服务重启通知，客户端在reconnect_after毫秒后重连
+func NewServerRestarting(reconnectAfter int64) *Action {
+	data := make(map[string]interface{})
+	data["reconnect_after"] = reconnectAfter
+	return &Action{
+		Data:   data,
+		Time:   time.Now().Unix(),
+		Action: ServerRestarting,
+	}
+}
+func NewTypingAction(action string, uid int64, adminId int64) *Action {
+	data := make(map[string]interface{})
+	data["user_id"] = uid
+	data["admin_id"] = adminId
+	data["timeout"] = TypingTimeout
+	return &Action{
+		Data:   data,
+		Time:   time.Now().Unix(),
+		Action: action,
+	}
+}
+func NewMessageReadAction(uid int64, adminId int64, msgId int64) *Action {
+	data := make(map[string]interface{})
+	data["user_id"] = uid
+	data["admin_id"] = adminId
+	data["msg_id"] = msgId
+	return &Action{
+		Data:   data,
+		Time:   time.Now().Unix(),
+		Action: MessageReadAction,
+	}
+}
+func NewMessageRecalledAction(msg *models.Message) *Action {
+	data := make(map[string]interface{})
+	data["msg_id"] = msg.Id
+	data["req_id"] = msg.ReqId
+	data["user_id"] = msg.UserId
+	data["admin_id"] = msg.AdminId
+	return &Action{
+		Data:   data,
+		Time:   time.Now().Unix(),
+		Action: MessageRecalled,
+	}
+}
+func NewMessageEditedAction(msg *models.Message) *Action {
+	data := make(map[string]interface{})
+	data["msg_id"] = msg.Id
+	data["req_id"] = msg.ReqId
+	data["user_id"] = msg.UserId
+	data["admin_id"] = msg.AdminId
+	data["content"] = msg.Content
+	data["edited_at"] = msg.EditedAt
+	return &Action{
+		Data:   data,
+		Time:   time.Now().Unix(),
+		Action: MessageEdited,
+	}
+}
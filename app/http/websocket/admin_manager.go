@@ -1,13 +1,15 @@
 package websocket
 
 import (
-	"fmt"
+	"errors"
 	"github.com/duke-git/lancet/v2/netutil"
+	"github.com/duke-git/lancet/v2/slice"
 	"github.com/spf13/viper"
 	"sort"
 	"time"
 	"ws/app/chat"
 	"ws/app/contract"
+	"ws/app/databases"
 	"ws/app/models"
 	"ws/app/repositories"
 	"ws/app/resource"
@@ -39,28 +41,113 @@ func SetupAdmin() {
 func (m *adminManager) Run() {
 	m.manager.Run()
 	go m.handleReceiveMessage()
+	go m.checkIdle()
+}
+
+// 空闲检查间隔
+const idleCheckPeriod = time.Minute
+
+// 定时检查本机上的客服，在线状态下空闲超过设定时间则自动切换为离开
+func (m *adminManager) checkIdle() {
+	ticker := time.NewTicker(idleCheckPeriod)
+	for {
+		<-ticker.C
+		checked := make(map[int64]struct{})
+		for _, conn := range m.GetTotalConn() {
+			if _, ok := checked[conn.GetUserId()]; ok {
+				continue
+			}
+			checked[conn.GetUserId()] = struct{}{}
+			admin, ok := conn.GetUser().(*models.Admin)
+			if !ok || admin.GetSetting().GetStatus() != models.AdminStatusOnline {
+				continue
+			}
+			duration := chat.SettingService.GetAwayDuration(admin.GetGroupId())
+			if duration <= 0 {
+				continue
+			}
+			if chat.AdminService.GetActiveTime(admin.GetPrimaryKey())+duration < time.Now().Unix() {
+				m.SetStatus(admin, models.AdminStatusAway, true)
+			}
+		}
+	}
+}
+
+// SetStatus 修改客服状态
+// 通知客服的所有连接刷新设置，并重新广播在线客服和待接入用户
+func (m *adminManager) SetStatus(admin *models.Admin, status string, isAuto bool) {
+	setting := admin.GetSetting()
+	repositories.AdminRepo.UpdateSettings(setting, map[string]interface{}{
+		"status":       status,
+		"is_auto_away": isAuto,
+	})
+	setting.Status = status
+	setting.IsAutoAway = isAuto
+	m.NoticeUpdateSetting(admin)
+	m.BroadcastOnlineAdmins(admin.GetGroupId())
+	m.BroadcastWaitingUser(admin.GetGroupId())
+	go m.AutoAssign(admin.GetGroupId())
+}
+
+// 客服活跃，自动离开的客服恢复在线
+func (m *adminManager) active(conn Conn) {
+	chat.AdminService.UpdateActiveTime(conn.GetUserId())
+	admin, ok := conn.GetUser().(*models.Admin)
+	if ok {
+		setting := admin.GetSetting()
+		if setting.GetStatus() == models.AdminStatusAway && setting.IsAutoAway {
+			m.SetStatus(admin, models.AdminStatusOnline, false)
+		}
+	}
+}
+
+// GetOnlineIdsByStatus 获取在线且处于指定状态的客服id
+func (m *adminManager) GetOnlineIdsByStatus(gid int64, statuses ...string) []int64 {
+	ids := m.GetOnlineUserIds(gid)
+	if len(ids) == 0 {
+		return ids
+	}
+	settings := make([]*models.AdminChatSetting, 0)
+	databases.Db.Where("admin_id in ?", ids).Where("status in ?", statuses).Find(&settings)
+	result := make([]int64, 0, len(settings))
+	for _, setting := range settings {
+		result = append(result, setting.AdminId)
+	}
+	return result
 }
 
 // DeliveryMessage
 // 投递消息
-// 查询admin是否在本机上，是则直接投递
-// 查询admin当前channel，如果存在则投递到该channel上
-// 最后则说明admin不在线，处理离线逻辑
+// admin在本机上的连接直接投递
+// 集群模式下再投递到admin所在的其他server上
+// 最后如果admin不在线，处理离线逻辑，admin离开时回复离线消息
 func (m *adminManager) DeliveryMessage(msg *models.Message, isRemote bool) {
-	adminConn, exist := m.GetConn(msg.GetAdmin())
+	adminConns, exist := m.GetConn(msg.GetAdmin())
 	if exist { // admin在线且在当前服务上
-		UserManager.triggerMessageEvent(models.SceneAdminOnline, msg)
-		adminConn.Deliver(NewReceiveAction(msg))
+		m.SendAction(NewReceiveAction(msg), adminConns...)
+	}
+	if isRemote {
+		if !exist { // 记录的server上已没有admin的连接
+			m.removeUserServer(msg.AdminId)
+		}
 		return
-	} else if !isRemote && m.isCluster() {
-		server := m.getUserServer(msg.AdminId) // 获取用户所在channel
-		if server != "" {
+	}
+	if m.isCluster() {
+		servers := m.getRemoteServers(msg.AdminId) // 获取admin所在的其他server
+		for _, server := range servers {
 			rpcClient.SendMessage(msg.Id, server)
-			return
 		}
+		exist = exist || len(servers) > 0
+	}
+	if exist {
+		UserManager.triggerMessageEvent(models.SceneAdminOnline, msg)
+		admin := repositories.AdminRepo.FirstById(msg.AdminId)
+		if admin != nil && admin.GetSetting().GetStatus() == models.AdminStatusAway {
+			m.sendOfflineMessage(msg, admin)
+		}
+		return
 	}
 	m.handleOffline(msg)
-
 }
 
 // 从管道接受消息并处理
@@ -78,28 +165,36 @@ func (m *adminManager) handleOffline(msg *models.Message) {
 	setting := admin.GetSetting()
 	if setting != nil {
 		// 发送离线消息
-		if setting.OfflineContent != "" {
-			offlineMsg := setting.GetOfflineMsg(msg.UserId, msg.SessionId, msg.GroupId)
-			offlineMsg.Admin = admin
-			repositories.MessageRepo.Save(offlineMsg)
-			UserManager.DeliveryMessage(offlineMsg, false)
-		}
+		m.sendOfflineMessage(msg, admin)
 		// 判断是否自动断开
 		lastOnline := setting.LastOnline
 		duration := chat.SettingService.GetOfflineDuration(msg.GroupId)
 		if (lastOnline.Unix() + duration) < time.Now().Unix() {
-			chat.SessionService.Close(msg.SessionId, false, true)
+			chat.SessionService.Close(msg.SessionId, models.CloseReasonOffline, false, true)
 			noticeMessage := admin.GetBreakMessage(msg.UserId, msg.SessionId) // 断开提醒
 			noticeMessage.Save()
 			UserManager.DeliveryMessage(noticeMessage, false)
+			UserManager.SendSurvey(msg.SessionId)
 		}
 	}
 }
 
+// 回复离线消息
+func (m *adminManager) sendOfflineMessage(msg *models.Message, admin *models.Admin) {
+	setting := admin.GetSetting()
+	if setting.OfflineContent != "" {
+		offlineMsg := setting.GetOfflineMsg(msg.UserId, msg.SessionId, msg.GroupId)
+		offlineMsg.Admin = admin
+		repositories.MessageRepo.Save(offlineMsg)
+		UserManager.DeliveryMessage(offlineMsg, false)
+	}
+}
+
 // 处理消息
 func (m *adminManager) handleMessage(payload *ConnMessage) {
 	act := payload.Action
 	conn := payload.Conn
+	m.active(conn)
 	switch act.Action {
 	// 客服发送消息给用户
 	case SendMessageAction:
@@ -121,6 +216,10 @@ func (m *adminManager) handleMessage(payload *ConnMessage) {
 				msg.ReceivedAT = time.Now().Unix()
 				msg.Admin = conn.User.(*models.Admin)
 				msg.SessionId = session.Id
+				if err := chat.MessageService.ValidateReply(msg); err != nil {
+					conn.Deliver(NewErrorMessage(err.Error()))
+					return
+				}
 				repositories.MessageRepo.Save(msg)
 				_ = chat.AdminService.UpdateUser(msg.AdminId, msg.UserId)
 				// 服务器回执d
@@ -128,17 +227,170 @@ func (m *adminManager) handleMessage(payload *ConnMessage) {
 				UserManager.DeliveryMessage(msg, false)
 			}
 		}
+	// 客服输入状态
+	case TypingStartAction, TypingStopAction:
+		uid := act.GetUserId()
+		if uid > 0 && chat.AdminService.IsUserValid(conn.GetUserId(), uid) {
+			UserManager.DeliveryTyping(act.Action, uid, conn.GetUserId(), false)
+		}
+	// 客服已读用户消息
+	case MessageReadAction:
+		uid := act.GetUserId()
+		if uid > 0 && chat.AdminService.IsUserExist(conn.GetUserId(), uid) {
+			msgId := repositories.MessageRepo.ReadByAdmin(conn.GetUserId(), uid, act.GetMsgId())
+			if msgId > 0 {
+				UserManager.DeliveryRead(uid, conn.GetUserId(), msgId, false)
+			}
+		}
+	// 客服撤回消息
+	case RecallMessageAction:
+		msg, err := m.RecallMessage(conn.GetUserId(), act.GetMsgId())
+		if err != nil {
+			conn.Deliver(NewErrorMessage(err.Error()))
+			return
+		}
+		conn.Deliver(NewMessageRecalledAction(msg))
+	// 客服编辑消息
+	case EditMessageAction:
+		msg, err := m.EditMessage(conn.GetUserId(), act.GetMsgId(), act.GetString("content"))
+		if err != nil {
+			conn.Deliver(NewErrorMessage(err.Error()))
+			return
+		}
+		conn.Deliver(NewMessageEditedAction(msg))
+	// 客服重连后补发last msg_id之后的用户消息
+	case SyncAction:
+		syncMessages(conn, act.GetMsgId(), []*repositories.Where{
+			{
+				Filed: "admin_id = ?",
+				Value: conn.GetUserId(),
+			},
+			{
+				Filed: "source = ?",
+				Value: models.SourceUser,
+			},
+		})
+	}
+}
+
+// EditMessage admin编辑自己发送的消息并通知user
+func (m *adminManager) EditMessage(adminId int64, msgId int64, content string) (*models.Message, error) {
+	msg := repositories.MessageRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: msgId,
+		},
+		{
+			Filed: "admin_id = ?",
+			Value: adminId,
+		},
+		{
+			Filed: "source = ?",
+			Value: models.SourceAdmin,
+		},
+	}, []string{})
+	if msg == nil {
+		return nil, errors.New("消息不存在")
+	}
+	err := chat.MessageService.Edit(msg, content)
+	if err != nil {
+		return nil, err
+	}
+	UserManager.DeliveryEdit(msg, false)
+	return msg, nil
+}
+
+// RecallMessage admin撤回自己发送的消息并通知user
+func (m *adminManager) RecallMessage(adminId int64, msgId int64) (*models.Message, error) {
+	msg := repositories.MessageRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: msgId,
+		},
+		{
+			Filed: "admin_id = ?",
+			Value: adminId,
+		},
+		{
+			Filed: "source = ?",
+			Value: models.SourceAdmin,
+		},
+	}, []string{})
+	if msg == nil {
+		return nil, errors.New("消息不存在")
+	}
+	err := chat.MessageService.Recall(msg)
+	if err != nil {
+		return nil, err
+	}
+	UserManager.DeliveryRecall(msg, false)
+	return msg, nil
+}
+
+// DeliveryRecall
+// 通知admin，user撤回了消息
+// 集群模式下同时投递到admin所在的其他server
+func (m *adminManager) DeliveryRecall(msg *models.Message, isRemote bool) {
+	if msg.AdminId == 0 {
+		return
+	}
+	conns, _ := m.GetConn(msg.GetAdmin())
+	m.SendAction(NewMessageRecalledAction(msg), conns...)
+	if !isRemote && m.isCluster() {
+		for _, server := range m.getRemoteServers(msg.AdminId) {
+			rpcClient.SendRecall(msg.Id, server)
+		}
+	}
+}
+
+// DeliveryRead
+// 通知admin，user已读到msgId
+// 集群模式下同时投递到admin所在的其他server，admin离线则丢弃
+func (m *adminManager) DeliveryRead(uid int64, adminId int64, msgId int64, isRemote bool) {
+	admin := repositories.AdminRepo.FirstById(adminId)
+	if admin == nil {
+		return
+	}
+	conns, _ := m.GetConn(admin)
+	m.SendAction(NewMessageReadAction(uid, adminId, msgId), conns...)
+	if !isRemote && m.isCluster() {
+		for _, server := range m.getRemoteServers(adminId) {
+			rpcClient.SendRead(m.GetTypes(), uid, adminId, msgId, server)
+		}
+	}
+}
+
+// DeliveryTyping
+// 投递user的输入状态给admin
+// 集群模式下同时投递到admin所在的其他server，admin离线则丢弃
+func (m *adminManager) DeliveryTyping(action string, uid int64, adminId int64, isRemote bool) {
+	admin := repositories.AdminRepo.FirstById(adminId)
+	if admin == nil {
+		return
+	}
+	conns, _ := m.GetConn(admin)
+	m.SendAction(NewTypingAction(action, uid, adminId), conns...)
+	if !isRemote && m.isCluster() {
+		for _, server := range m.getRemoteServers(adminId) {
+			rpcClient.SendTyping(m.GetTypes(), action, uid, adminId, server)
+		}
 	}
 }
 
 func (m *adminManager) registerHook(conn Conn) {
+	m.active(conn)
 	m.NoticeUserTransfer(conn.GetUser())
 	m.BroadcastOnlineAdmins(conn.GetGroupId())
 	m.BroadcastWaitingUser(conn.GetGroupId())
+	go m.AutoAssign(conn.GetGroupId())
 }
 
 // conn断开连接后，更新admin的最后在线时间
 func (m *adminManager) unregisterHook(conn Conn) {
+	// 排空连接期间客服会重连到其他server，不视为离线
+	if m.IsDraining() {
+		return
+	}
 	u := conn.GetUser()
 	admin, ok := u.(*models.Admin)
 	if ok {
@@ -160,7 +412,10 @@ func (m *adminManager) BroadcastLocalWaitingUser(groupId int64) {
 	sessions := repositories.ChatSessionRepo.GetWaitHandles()
 	userMap := make(map[int64]*models.User)
 	waitingUser := make([]*resource.WaitingChatSession, 0, len(sessions))
+	// 按排队的有效顺序排列
+	scores := make(map[int64]float64, len(sessions))
 	for _, session := range sessions {
+		scores[session.UserId] = chat.ManualService.GetTime(session.UserId, session.GroupId)
 		userMap[session.UserId] = session.User
 		msgs := make([]*resource.SimpleMessage, 0, len(session.Messages))
 		for _, m := range session.Messages {
@@ -179,18 +434,35 @@ func (m *adminManager) BroadcastLocalWaitingUser(groupId int64) {
 			Messages:     msgs,
 			LastTime:     session.QueriedAt,
 			SessionId:    session.Id,
+			QueueId:      session.QueueId,
+			Priority:     session.User.Priority,
 		})
 	}
-	sort.Slice(waitingUser, func(i, j int) bool {
-		return waitingUser[i].LastTime > waitingUser[j].LastTime
+	sort.SliceStable(waitingUser, func(i, j int) bool {
+		return scores[waitingUser[i].UserId] < scores[waitingUser[j].UserId]
 	})
 	adminConns := m.GetAllConn(groupId)
+	// 一次查出分组中客服所属的队列
+	var adminQueueIds map[int64][]int64
+	if len(adminConns) > 0 {
+		adminQueueIds = repositories.ChatQueueRepo.GetIdsByGroupAdmins(groupId)
+	}
 	for _, conn := range adminConns {
 		adminUserSlice := make([]*resource.WaitingChatSession, 0)
+		admin := conn.GetUser().(*models.Admin)
+		// 忙碌或离开的客服不推送待接入用户
+		if !admin.GetSetting().IsAcceptable() {
+			conn.Deliver(NewWaitingUsers(adminUserSlice))
+			continue
+		}
+		// 只推送默认队列和客服所属队列中的用户
+		queueIds := map[int64]struct{}{0: {}}
+		for _, id := range adminQueueIds[admin.GetPrimaryKey()] {
+			queueIds[id] = struct{}{}
+		}
 		for _, userJson := range waitingUser {
 			u := userMap[userJson.UserId]
-			admin := conn.GetUser().(*models.Admin)
-			if admin.AccessTo(u) {
+			if _, ok := queueIds[userJson.QueueId]; ok && admin.AccessTo(u) {
 				adminUserSlice = append(adminUserSlice, userJson)
 			}
 		}
@@ -214,61 +486,59 @@ func (m *adminManager) BroadcastLocalOnlineAdmins(gid int64) {
 	}}, -1, []string{}, []string{})
 	data := make([]resource.Admin, 0, len(admins))
 	for _, admin := range admins {
+		setting := admin.GetSetting()
+		// 隐身的客服不显示
+		if !setting.IsVisible() {
+			continue
+		}
 		data = append(data, resource.Admin{
 			Avatar:        admin.GetAvatarUrl(),
 			Username:      admin.Username,
 			Online:        true,
+			Status:        setting.GetStatus(),
 			Id:            admin.GetPrimaryKey(),
 			AcceptedCount: chat.AdminService.GetActiveCount(admin.GetPrimaryKey()),
+			MaxChats:      chat.AdminService.GetMaxChats(admin),
 		})
 	}
 	m.SendAction(NewAdminsAction(data), m.GetAllConn(gid)...)
 }
 
 func (m *adminManager) NoticeUserOffline(user contract.User) {
+	m.NoticeLocalUserOffline(user.GetPrimaryKey())
 	m.Do(func() {
 		adminId := chat.UserService.GetValidAdmin(user.GetPrimaryKey())
-		server := m.getUserServer(adminId)
-		fmt.Println(server)
-		if server != "" {
+		for _, server := range m.getRemoteServers(adminId) {
 			rpcClient.NoticeUserOffLine(user.GetPrimaryKey(), server)
 		}
-	}, func() {
-		m.NoticeLocalUserOffline(user.GetPrimaryKey())
-	})
+	}, nil)
 }
 
 func (m *adminManager) NoticeLocalUserOffline(uid int64) {
 	adminId := chat.UserService.GetValidAdmin(uid)
 	admin := repositories.AdminRepo.FirstById(adminId)
 	if admin != nil {
-		conn, exist := m.GetConn(admin)
-		if exist {
-			m.SendAction(NewUserOffline(uid), conn)
-		}
+		conns, _ := m.GetConn(admin)
+		m.SendAction(NewUserOffline(uid), conns...)
 	}
 }
 
 func (m *adminManager) NoticeUserOnline(user contract.User) {
+	m.NoticeLocalUserOnline(user.GetPrimaryKey())
 	m.Do(func() {
 		adminId := chat.UserService.GetValidAdmin(user.GetPrimaryKey())
-		server := m.getUserServer(adminId)
-		if server != "" {
+		for _, server := range m.getRemoteServers(adminId) {
 			rpcClient.NoticeUserOnline(user.GetPrimaryKey(), server)
 		}
-	}, func() {
-		m.NoticeLocalUserOnline(user.GetPrimaryKey())
-	})
+	}, nil)
 }
 
 func (m *adminManager) NoticeLocalUserOnline(uid int64) {
 	adminId := chat.UserService.GetValidAdmin(uid)
 	admin := repositories.AdminRepo.FirstById(adminId)
 	if admin != nil {
-		conn, exist := m.GetConn(admin)
-		if exist {
-			m.SendAction(NewUserOnline(uid), conn)
-		}
+		conns, _ := m.GetConn(admin)
+		m.SendAction(NewUserOnline(uid), conns...)
 	}
 }
 
@@ -288,18 +558,16 @@ func (m *adminManager) NoticeLocalUserOnline(uid int64) {
 //}
 
 func (m *adminManager) NoticeUserTransfer(admin contract.User) {
+	m.NoticeLocalUserTransfer(admin)
 	m.Do(func() {
-		server := m.getUserServer(admin.GetPrimaryKey())
-		if server != "" {
+		for _, server := range m.getRemoteServers(admin.GetPrimaryKey()) {
 			rpcClient.NoticeUserTransfer(admin.GetPrimaryKey(), server)
 		}
-	}, func() {
-		m.NoticeLocalUserTransfer(admin)
-	})
+	}, nil)
 }
 
 func (m *adminManager) NoticeLocalUserTransfer(admin contract.User) {
-	client, exist := m.GetConn(admin)
+	conns, exist := m.GetConn(admin)
 	if exist {
 		transfers := repositories.TransferRepo.Get([]*repositories.Where{
 			{
@@ -315,30 +583,40 @@ func (m *adminManager) NoticeLocalUserTransfer(admin contract.User) {
 				Value: 0,
 			},
 		}, -1, []string{"FromAdmin", "User"}, []string{"id desc"})
+		// 转接时附带用户的内部备注
+		notes := make(map[int64][]*resource.Note)
+		if len(transfers) > 0 {
+			uids := slice.Map(transfers, func(index int, transfer *models.ChatTransfer) int64 {
+				return transfer.UserId
+			})
+			for _, note := range repositories.NoteRepo.GetByUsers(uids) {
+				notes[note.UserId] = append(notes[note.UserId], note.ToJson())
+			}
+		}
 		data := make([]*resource.ChatTransfer, 0, len(transfers))
 		for _, transfer := range transfers {
-			data = append(data, transfer.ToJson())
+			json := transfer.ToJson()
+			json.Notes = notes[transfer.UserId]
+			data = append(data, json)
 		}
-		client.Deliver(NewUserTransfer(data))
+		m.SendAction(NewUserTransfer(data), conns...)
 	}
 }
 
 // NoticeUpdateSetting admin修改设置后通知conn 更新admin的设置信息
 func (m *adminManager) NoticeUpdateSetting(admin contract.User) {
+	m.UpdateSetting(admin)
 	m.Do(func() {
-		server := m.getUserServer(admin.GetPrimaryKey())
-		if server != "" {
+		for _, server := range m.getRemoteServers(admin.GetPrimaryKey()) {
 			rpcClient.NoticeUpdateSetting(admin.GetPrimaryKey(), server)
 		}
-	}, func() {
-		m.UpdateSetting(admin)
-	})
+	}, nil)
 }
 
 // UpdateSetting 更新设置
 func (m *adminManager) UpdateSetting(admin contract.User) {
-	conn, exist := m.GetConn(admin)
-	if exist {
+	conns, _ := m.GetConn(admin)
+	for _, conn := range conns {
 		u, ok := conn.GetUser().(*models.Admin)
 		if ok {
 			u.RefreshSetting()
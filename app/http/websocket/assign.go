@@ -0,0 +1,178 @@
+package websocket
+
+import (
+	"time"
+	"ws/app/chat"
+	"ws/app/databases"
+	"ws/app/models"
+	"ws/app/repositories"
+	"ws/app/resource"
+	rpcClient "ws/app/rpc/client"
+)
+
+// AcceptUser 客服接入用户，手动接入和自动分配共用
+// 更新会话和未发送的消息，通知用户已接入，并广播待接入用户和排队位置
+// 返回客服端的用户信息
+func (m *adminManager) AcceptUser(admin *models.Admin, user *models.User, session *models.ChatSession) *resource.User {
+	unSendMsg := repositories.MessageRepo.GetUnSend([]*repositories.Where{
+		{
+			Filed: "user_id = ?",
+			Value: user.GetPrimaryKey(),
+		},
+		{
+			Filed: "session_id = ?",
+			Value: session.Id,
+		},
+	})
+	session.AcceptedAt = time.Now().Unix()
+	session.AdminId = admin.GetPrimaryKey()
+	repositories.ChatSessionRepo.Save(session)
+	_ = chat.AdminService.AddUser(admin, user)
+	now := time.Now().Unix()
+	// 更新未发送的消息
+	repositories.MessageRepo.Update([]*repositories.Where{
+		{
+			Filed: "user_id = ?",
+			Value: user.GetPrimaryKey(),
+		},
+		{
+			Filed: "source = ?",
+			Value: models.SourceUser,
+		},
+		{
+			Filed: "session_id = ?",
+			Value: session.Id,
+		},
+	}, map[string]interface{}{
+		"admin_id": admin.GetPrimaryKey(),
+		"send_at":  now,
+	})
+	chatUser := m.getChatUser(admin, user, len(unSendMsg))
+	noticeMessage := repositories.MessageRepo.NewNotice(session, admin.GetChatName()+"为您服务")
+	repositories.MessageRepo.Save(noticeMessage)
+	UserManager.DeliveryMessage(noticeMessage, false)
+	go m.BroadcastWaitingUser(user.GetGroupId())
+	go UserManager.BroadcastQueueLocation(user.GetGroupId())
+	return chatUser
+}
+
+// 客服端的用户信息，包含最近的聊天记录
+func (m *adminManager) getChatUser(admin *models.Admin, user *models.User, unread int) *resource.User {
+	messages := repositories.MessageRepo.Get([]*repositories.Where{
+		{
+			Filed: "user_id = ?",
+			Value: user.GetPrimaryKey(),
+		},
+		{
+			Filed: "admin_id = ?",
+			Value: admin.GetPrimaryKey(),
+		},
+		{
+			Filed: "source in ?",
+			Value: []int{models.SourceAdmin, models.SourceUser},
+		},
+	}, 20, []string{"User", "Admin", "Reply"}, []string{"id desc"})
+	chatUser := &resource.User{
+		ID:           user.GetPrimaryKey(),
+		Username:     user.GetUsername(),
+		LastChatTime: time.Now().Unix(),
+		Messages:     make([]*resource.Message, 0, len(messages)),
+		Avatar:       user.GetAvatarUrl(),
+		Unread:       unread,
+		Online:       UserManager.IsOnline(user),
+	}
+	for _, msg := range messages {
+		chatUser.Messages = append(chatUser.Messages, msg.ToJson())
+	}
+	return chatUser
+}
+
+// AutoAssign 将待接入的用户按排队顺序自动分配给开启了自动接入的在线客服
+// 集群中多个server可能同时执行，通过用户的接入锁保证同一用户只会被接入一次
+func (m *adminManager) AutoAssign(gid int64) {
+	adminIds := m.getAutoAcceptIds(gid)
+	if len(adminIds) == 0 {
+		return
+	}
+	admins := repositories.AdminRepo.Get([]*repositories.Where{
+		{
+			Filed: "id in ?",
+			Value: adminIds,
+		},
+	}, -1, []string{}, []string{})
+	for _, uid := range chat.AssignService.GetWaitingUsers(gid) {
+		m.assign(gid, uid, admins)
+	}
+}
+
+// 分配单个用户
+func (m *adminManager) assign(gid int64, uid int64, admins []*models.Admin) bool {
+	if !chat.AssignService.Lock(uid) {
+		return false
+	}
+	defer chat.AssignService.Unlock(uid)
+	if chat.UserService.GetValidAdmin(uid) != 0 || chat.TransferService.GetUserTransferId(uid) != 0 {
+		return false
+	}
+	user := repositories.UserRepo.FirstById(uid)
+	if user == nil {
+		return false
+	}
+	session := repositories.ChatSessionRepo.FirstActiveByUser(uid, 0)
+	if session == nil || session.Type != models.ChatSessionTypeNormal {
+		return false
+	}
+	adminMap := make(map[int64]*models.Admin, len(admins))
+	candidates := make([]int64, 0, len(admins))
+	for _, admin := range admins {
+		// 只分配给队列的成员，接待人数已满的客服不再分配
+		if admin.AccessTo(user) &&
+			repositories.ChatQueueRepo.IsMember(session.QueueId, admin.GetPrimaryKey()) &&
+			chat.AdminService.CheckCapacity(admin) == nil {
+			adminMap[admin.GetPrimaryKey()] = admin
+			candidates = append(candidates, admin.GetPrimaryKey())
+		}
+	}
+	admin, ok := adminMap[chat.AssignService.SelectAdmin(gid, candidates)]
+	if !ok {
+		return false
+	}
+	chatUser := m.AcceptUser(admin, user, session)
+	m.NoticeUserAssigned(admin, user, chatUser.Unread)
+	return true
+}
+
+// 获取在线、可接入且开启了自动接入的客服id
+func (m *adminManager) getAutoAcceptIds(gid int64) []int64 {
+	ids := m.GetOnlineUserIds(gid)
+	if len(ids) == 0 {
+		return ids
+	}
+	settings := make([]*models.AdminChatSetting, 0)
+	databases.Db.Where("admin_id in ?", ids).
+		Where("status in ?", []string{models.AdminStatusOnline, models.AdminStatusInvisible}).
+		Where("is_auto_accept = ?", true).
+		Find(&settings)
+	result := make([]int64, 0, len(settings))
+	for _, setting := range settings {
+		result = append(result, setting.AdminId)
+	}
+	return result
+}
+
+// NoticeUserAssigned 通知客服有用户被自动分配
+func (m *adminManager) NoticeUserAssigned(admin *models.Admin, user *models.User, unread int) {
+	m.NoticeLocalUserAssigned(admin, user, unread)
+	m.Do(func() {
+		for _, server := range m.getRemoteServers(admin.GetPrimaryKey()) {
+			rpcClient.NoticeUserAssigned(admin.GetPrimaryKey(), user.GetPrimaryKey(), unread, server)
+		}
+	}, nil)
+}
+
+func (m *adminManager) NoticeLocalUserAssigned(admin *models.Admin, user *models.User, unread int) {
+	conns, exist := m.GetConn(admin)
+	if exist {
+		m.SendAction(NewUserAssigned(m.getChatUser(admin, user, unread)), conns...)
+	}
+}
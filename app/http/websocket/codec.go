@@ -0,0 +1,126 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	CodecJson     = "json"
+	CodecMsgpack  = "msgpack"
+	CodecProtobuf = "protobuf"
+)
+
+// Subprotocols 升级websocket时可协商的子协议(编码)，按优先级排列
+// 客户端未通过Sec-WebSocket-Protocol指定时默认使用json
+var Subprotocols = []string{CodecMsgpack, CodecProtobuf, CodecJson}
+
+// Codec websocket消息编解码
+type Codec interface {
+	Name() string
+	MessageType() int // websocket帧类型
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte, v interface{}) error
+}
+
+// GetCodec 根据协商的子协议获取编解码器
+func GetCodec(subprotocol string) Codec {
+	switch subprotocol {
+	case CodecMsgpack:
+		return msgpackCodec{}
+	case CodecProtobuf:
+		return protobufCodec{}
+	default:
+		return jsonCodec{}
+	}
+}
+
+type jsonCodec struct {
+}
+
+func (codec jsonCodec) Name() string {
+	return CodecJson
+}
+
+func (codec jsonCodec) MessageType() int {
+	return websocket.TextMessage
+}
+
+func (codec jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (codec jsonCodec) Unmarshal(b []byte, v interface{}) error {
+	return json.Unmarshal(b, v)
+}
+
+// msgpack编码，字段名沿用json tag
+type msgpackCodec struct {
+}
+
+func (codec msgpackCodec) Name() string {
+	return CodecMsgpack
+}
+
+func (codec msgpackCodec) MessageType() int {
+	return websocket.BinaryMessage
+}
+
+func (codec msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	err := enc.Encode(v)
+	return buf.Bytes(), err
+}
+
+func (codec msgpackCodec) Unmarshal(b []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.SetCustomStructTag("json")
+	// 整数统一解码为int64/uint64，浮点数解码为float64
+	dec.UseLooseInterfaceDecoding(true)
+	return dec.Decode(v)
+}
+
+// protobuf编码，消息体为google.protobuf.Struct，字段与json一致，客户端无需额外的proto定义
+type protobufCodec struct {
+}
+
+func (codec protobufCodec) Name() string {
+	return CodecProtobuf
+}
+
+func (codec protobufCodec) MessageType() int {
+	return websocket.BinaryMessage
+}
+
+func (codec protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	s := &structpb.Struct{}
+	err = protojson.Unmarshal(b, s)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(s)
+}
+
+func (codec protobufCodec) Unmarshal(b []byte, v interface{}) error {
+	s := &structpb.Struct{}
+	err := proto.Unmarshal(b, s)
+	if err != nil {
+		return err
+	}
+	j, err := protojson.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(j, v)
+}
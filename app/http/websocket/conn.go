@@ -13,17 +13,25 @@ import (
 	"ws/app/log"
 	"ws/app/models"
 	"ws/app/repositories"
+	"ws/config"
 )
 
-func NewConn(user contract.User, conn *websocket.Conn, manager ConnManager) Conn {
+func NewConn(user contract.User, conn *websocket.Conn, manager ConnManager, codec Codec) Conn {
+	return newClient(user, newWsTransport(conn, codec), manager, codec)
+}
+
+func newClient(user contract.User, transport Transport, manager ConnManager, codec Codec) *Client {
 	return &Client{
-		conn:        conn,
+		transport:   transport,
+		codec:       codec,
 		closeSignal: make(chan interface{}),
-		send:        make(chan *Action, 100),
+		send:        newSendQueue(config.GetSendQueueSize(), config.GetOverflowStrategy()),
 		manager:     manager,
 		User:        user,
 		uuid:        uuid.NewV4().String(),
 		limiter:     rate.NewLimiter(5, 10),
+		typing:      make(map[int64]*typingState),
+		pending:     make(map[int64]*pendingMessage),
 	}
 }
 
@@ -41,17 +49,53 @@ type Conn interface {
 }
 
 type Client struct {
-	conn        *websocket.Conn
-	closeSignal chan interface{} // 连接断开后的广播通道，用于中断readMsg,sendMsg goroutine
-	send        chan *Action     // 发送的消息chan
+	transport   Transport        // 传输层，websocket或sse
+	codec       Codec            // 协商的编解码器
+	closeSignal chan interface{} // 连接断开后的广播通道，用于中断sendMsg等goroutine
+	send        *sendQueue       // 待发送的消息队列
 	sync.Once
-	manager ConnManager
-	User    contract.User
-	uuid    string
-	Created int64
-	limiter *rate.Limiter
+	manager      ConnManager
+	User         contract.User
+	uuid         string
+	Created      int64
+	limiter      *rate.Limiter
+	typing       map[int64]*typingState // 输入状态，key为对方user_id，user端固定为0
+	typingMutex  sync.Mutex
+	reliable     bool                      // 客户端发送过sync后开启ack模式
+	pending      map[int64]*pendingMessage // 已发送未ack的消息，key为消息id
+	pendingMutex sync.Mutex
+}
+
+// 已发送未ack的消息
+type pendingMessage struct {
+	message *models.Message
+	sentAt  time.Time
+	times   int // 发送次数
+}
+
+const (
+	ackTimeout   = 5 * time.Second // 超过该时间未ack则重新发送
+	maxSendTimes = 3               // 最多发送次数，超过后等待客户端重连时sync补发
+	syncLimit    = 100             // 单次sync补发的最大消息数
+)
+
+const (
+	writeWait      = 10 * time.Second // 单次写超时
+	pongWait       = 25 * time.Second // 超过该时间未收到任何帧(包括pong)则视为连接断开
+	pingPeriod     = 10 * time.Second // ping帧发送间隔，需小于pongWait
+	MaxMessageSize = 32 * 1024        // 单条消息最大字节数
+)
+
+// 输入状态
+type typingState struct {
+	timer     *time.Timer // 超时自动停止
+	forwardAt time.Time   // 上次转发时间，用于节流
+	data      interface{}
 }
 
+// typing-start转发的最小间隔
+const typingThrottle = 2 * time.Second
+
 func (c *Client) GetCreateTime() int64 {
 	return c.Created
 }
@@ -77,33 +121,86 @@ func (c *Client) GetUserId() int64 {
 func (c *Client) run() {
 	go c.readMsg()
 	go c.sendMsg()
+	go c.resendMsg()
 }
 
 //幂等的close方法 关闭连接，相关清理
 func (c *Client) close() {
 	c.Once.Do(func() {
 		close(c.closeSignal)
-		_ = c.conn.Close()
+		_ = c.transport.Close()
+		c.stopAllTyping()
 		c.manager.Unregister(c)
 	})
 }
 
+// 验证从websocket读取的action
+func (c *Client) validate(act *Action) error {
+	switch act.Action {
+	case TypingStartAction, TypingStopAction:
+		return nil
+	case SyncAction:
+		// 新客户端没有已收到的消息时msg_id为0，只开启ack模式不补发
+		if act.GetMsgId() < 0 {
+			return errors.New("消息不合法")
+		}
+		return nil
+	case AckAction, MessageReadAction, RecallMessageAction:
+		if act.GetMsgId() <= 0 {
+			return errors.New("消息不合法")
+		}
+		return nil
+	case ButtonClickAction:
+		if !c.limiter.Allow() {
+			return errors.New("发送过于频繁，请慢一些")
+		}
+		value := act.GetString("value")
+		if act.GetMsgId() <= 0 || value == "" || len(value) > 128 {
+			return errors.New("消息不合法")
+		}
+		return nil
+	case SurveyAction:
+		rating := act.GetInt("rating")
+		if act.GetMsgId() <= 0 || rating < 1 || rating > 5 {
+			return errors.New("评价不合法")
+		}
+		if utf8.RuneCountInString(act.GetString("comment")) > 512 {
+			return errors.New("评价内容不能超过512个字")
+		}
+		return nil
+	case EditMessageAction:
+		if act.GetMsgId() <= 0 {
+			return errors.New("消息不合法")
+		}
+		if !c.limiter.Allow() {
+			return errors.New("发送过于频繁，请慢一些")
+		}
+		data, _ := act.Data.(map[string]interface{})
+		// 修改后的内容必须是非空字符串
+		content, ok := data["content"].(string)
+		if !ok || content == "" {
+			return errors.New("请勿发送空内容")
+		}
+		return c.validateContent(content)
+	default:
+		data, ok := act.Data.(map[string]interface{})
+		if !ok {
+			return errors.New("消息不合法")
+		}
+		return c.validateMessage(data)
+	}
+}
+
 // 发送消息验证
-func (c *Client) validate(data map[string]interface{}) error {
+func (c *Client) validateMessage(data map[string]interface{}) error {
 	if !c.limiter.Allow() {
 		return errors.New("发送过于频繁，请慢一些")
 	}
 	content, exist := data["content"]
 	if exist {
-		s, ok := content.(string)
-		if ok {
-			length := utf8.RuneCountInString(s)
-			if length == 0 {
-				return errors.New("请勿发送空内容")
-			}
-			if length > 512 {
-				return errors.New("内容长度必须小于512个字符")
-			}
+		err := c.validateContent(content)
+		if err != nil {
+			return err
 		}
 	}
 	reqId, exist := data["req_id"]
@@ -126,6 +223,13 @@ func (c *Client) validate(data map[string]interface{}) error {
 	} else {
 		typeStr, ok := types.(string)
 		if ok {
+			if models.IsPayloadType(typeStr) {
+				payload, ok := data["payload"].(map[string]interface{})
+				if !ok {
+					return errors.New("消息不合法")
+				}
+				return models.Payload(payload).Validate(typeStr)
+			}
 			if typeStr != models.TypeText && typeStr != models.TypeImage {
 				return errors.New("消息不合法")
 			}
@@ -136,99 +240,293 @@ func (c *Client) validate(data map[string]interface{}) error {
 	return nil
 }
 
-// 从websocket读消息
+// 消息内容验证
+func (c *Client) validateContent(content interface{}) error {
+	s, ok := content.(string)
+	if ok {
+		length := utf8.RuneCountInString(s)
+		if length == 0 {
+			return errors.New("请勿发送空内容")
+		}
+		if length > 512 {
+			return errors.New("内容长度必须小于512个字符")
+		}
+	}
+	return nil
+}
+
+// 从transport读消息
+// 每个连接只有一个读goroutine
 func (c *Client) readMsg() {
-	var msg = make(chan []byte, 50)
+	// 读消息失败(包括读超时)说明连接异常，调用close方法
+	defer c.close()
 	for {
-		go func() {
-			_, message, err := c.conn.ReadMessage()
-			// 读消息失败说明连接异常，调用close方法
+		msgStr, err := c.transport.ReadMessage()
+		if err != nil {
+			return
+		}
+		var act = &Action{}
+		err = act.Decode(c.codec, msgStr)
+		if err == nil {
+			err = c.validate(act)
 			if err != nil {
-				c.close()
-			} else {
-				msg <- message
+				c.Deliver(NewErrorMessage(err.Error()))
+			} else if c.shouldForward(act) {
+				log.Log.WithField("a-type", "websocket").
+					WithField("b-type", c.manager.GetTypes()).
+					WithField("c-type", "read-message").
+					Infof("<user-id:%d><action:%s> %s",
+						c.GetUserId(),
+						act.Action,
+						msgStr)
+				c.manager.ReceiveMessage(&ConnMessage{
+					Action: act,
+					Conn:   c,
+				})
 			}
-		}()
+		} else {
+			exceptions.Handler(err)
+		}
+	}
+}
+
+// 是否需要交给manager处理
+// 输入状态不落库，typing-start按typingThrottle节流，未开始输入的typing-stop直接忽略
+func (c *Client) shouldForward(act *Action) bool {
+	switch act.Action {
+	case TypingStartAction:
+		return c.startTyping(act)
+	case TypingStopAction:
+		return c.stopTyping(act.GetUserId())
+	case AckAction:
+		c.ack(act.GetMsgId())
+		return false
+	case SyncAction:
+		c.pendingMutex.Lock()
+		c.reliable = true
+		c.pendingMutex.Unlock()
+	}
+	return true
+}
+
+// 消息发送成功后的处理
+// ack模式下等待客户端ack后才标记send_at，否则直接标记
+func (c *Client) sent(msg *models.Message) {
+	c.pendingMutex.Lock()
+	if !c.reliable {
+		c.pendingMutex.Unlock()
+		// 释放锁后再写库，避免阻塞ack和重发
+		repositories.MessageRepo.UpdateById(msg.Id, map[string]interface{}{
+			"send_at": time.Now().Unix(),
+		})
+		return
+	}
+	defer c.pendingMutex.Unlock()
+	p, exist := c.pending[msg.Id]
+	if exist {
+		p.times++
+		p.sentAt = time.Now()
+	} else {
+		c.pending[msg.Id] = &pendingMessage{
+			message: msg,
+			sentAt:  time.Now(),
+			times:   1,
+		}
+	}
+}
+
+// 客户端ack，只处理发送给当前连接的消息
+func (c *Client) ack(msgId int64) {
+	c.pendingMutex.Lock()
+	_, exist := c.pending[msgId]
+	delete(c.pending, msgId)
+	c.pendingMutex.Unlock()
+	if exist {
+		repositories.MessageRepo.UpdateById(msgId, map[string]interface{}{
+			"send_at": time.Now().Unix(),
+		})
+	}
+}
+
+// 重新发送超时未ack的消息
+func (c *Client) resendMsg() {
+	ticker := time.NewTicker(ackTimeout)
+	defer ticker.Stop()
+	for {
 		select {
-		case <-c.closeSignal:
-			return
-		case msgStr := <-msg:
-			var act = &Action{}
-			err := act.UnMarshal(msgStr)
-			if err == nil {
-				data, ok := act.Data.(map[string]interface{})
-				if ok {
-					err = c.validate(data)
-					if err != nil {
-						c.Deliver(NewErrorMessage(err.Error()))
-					} else {
-						log.Log.WithField("a-type", "websocket").
-							WithField("b-type", c.manager.GetTypes()).
-							WithField("c-type", "read-message").
-							Infof("<user-id:%d><action:%s> %s",
-								c.GetUserId(),
-								act.Action,
-								msgStr)
-						c.manager.ReceiveMessage(&ConnMessage{
-							Action: act,
-							Conn:   c,
-						})
-					}
+		case <-ticker.C:
+			resend := make([]*models.Message, 0)
+			c.pendingMutex.Lock()
+			for id, p := range c.pending {
+				if time.Since(p.sentAt) < ackTimeout {
+					continue
+				}
+				if p.times >= maxSendTimes {
+					delete(c.pending, id)
+					continue
 				}
-			} else {
-				exceptions.Handler(err)
+				resend = append(resend, p.message)
 			}
+			c.pendingMutex.Unlock()
+			for _, msg := range resend {
+				c.Deliver(NewReceiveAction(msg))
+			}
+		case <-c.closeSignal:
+			return
+		}
+	}
+}
 
+// 开始输入，超过TypingTimeout未刷新则自动停止
+func (c *Client) startTyping(act *Action) bool {
+	c.typingMutex.Lock()
+	defer c.typingMutex.Unlock()
+	key := act.GetUserId()
+	state, exist := c.typing[key]
+	if exist {
+		state.timer.Reset(TypingTimeout * time.Second)
+		if time.Since(state.forwardAt) < typingThrottle {
+			return false
 		}
+		state.forwardAt = time.Now()
+		return true
+	}
+	c.typing[key] = &typingState{
+		timer: time.AfterFunc(TypingTimeout*time.Second, func() {
+			c.expireTyping(key)
+		}),
+		forwardAt: time.Now(),
+		data:      act.Data,
+	}
+	return true
+}
+
+// 停止输入
+func (c *Client) stopTyping(key int64) bool {
+	c.typingMutex.Lock()
+	defer c.typingMutex.Unlock()
+	state, exist := c.typing[key]
+	if exist {
+		state.timer.Stop()
+		delete(c.typing, key)
 	}
+	return exist
 }
 
-// Deliver 投递消息
+// 输入状态过期，代替客户端发送typing-stop
+func (c *Client) expireTyping(key int64) {
+	c.typingMutex.Lock()
+	state, exist := c.typing[key]
+	if exist {
+		delete(c.typing, key)
+	}
+	c.typingMutex.Unlock()
+	if exist {
+		c.manager.ReceiveMessage(&ConnMessage{
+			Action: &Action{
+				Action: TypingStopAction,
+				Time:   time.Now().Unix(),
+				Data:   state.data,
+			},
+			Conn: c,
+		})
+	}
+}
+
+// 连接断开时停止所有输入状态
+func (c *Client) stopAllTyping() {
+	c.typingMutex.Lock()
+	keys := make([]int64, 0, len(c.typing))
+	for key, state := range c.typing {
+		state.timer.Stop()
+		keys = append(keys, key)
+	}
+	c.typingMutex.Unlock()
+	for _, key := range keys {
+		c.expireTyping(key)
+	}
+}
+
+// Deliver 投递消息，不阻塞调用方
+// 队列满时按配置丢弃最早的消息或断开连接，广播类消息只保留最新的一条
 func (c *Client) Deliver(act *Action) {
-	c.send <- act
+	select {
+	case <-c.closeSignal:
+		return
+	default:
+	}
+	result := c.send.push(act)
+	c.manager.GetMetrics().incr(result)
+	switch result {
+	case pushDropped:
+		log.Log.WithField("a-type", "websocket").
+			WithField("b-type", c.manager.GetTypes()).
+			WithField("c-type", "drop-message").
+			Warnf("<user-id:%d><action:%s> send queue full, drop oldest", c.GetUserId(), act.Action)
+	case pushOverflow:
+		log.Log.WithField("a-type", "websocket").
+			WithField("b-type", c.manager.GetTypes()).
+			WithField("c-type", "drop-message").
+			Warnf("<user-id:%d><action:%s> send queue full, disconnect", c.GetUserId(), act.Action)
+		// 调用方可能持有manager的锁，异步关闭
+		go c.close()
+	}
 }
 
-// 向websocket发消息
+// 向transport发消息
 func (c *Client) sendMsg() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
 	for {
 		select {
-		case act := <-c.send:
-			msgStr, err := act.Marshal()
-			if err == nil {
-				err := c.conn.WriteMessage(websocket.TextMessage, msgStr)
-				log.Log.WithField("a-type", "websocket").
-					WithField("b-type", c.manager.GetTypes()).
-					WithField("b-type", "send-message").
-					Infof("<user-id:%d><action:%s> %s",
-						c.GetUserId(),
-						act.Action,
-						msgStr)
-				if err == nil {
-					switch act.Action {
-					case MoreThanOne:
-						c.close()
-					case OtherLogin:
-						c.close()
-					case ReceiveMessageAction:
-						msg, ok := act.Data.(*models.Message)
-						if ok {
-							repositories.MessageRepo.UpdateById(msg.Id, map[string]interface{}{
-								"send_at": time.Now().Unix(),
-							})
-						}
-					default:
-					}
-				} else {
-					exceptions.Handler(err)
-					// 发送失败，close
-					c.close()
+		case <-ticker.C:
+			// 客户端意外断开时服务器没有关闭事件，通过心跳探测
+			if err := c.transport.Ping(); err != nil {
+				c.close()
+				return
+			}
+		case <-c.send.notify:
+			for _, act := range c.send.popAll() {
+				if !c.write(act) {
 					return
 				}
-			} else {
-				exceptions.Handler(err)
 			}
 		case <-c.closeSignal:
 			return
 		}
 	}
 }
+
+// 写入一条消息，返回false表示连接已关闭
+func (c *Client) write(act *Action) bool {
+	msgStr, err := act.Encode(c.codec)
+	if err != nil {
+		exceptions.Handler(err)
+		return true
+	}
+	err = c.transport.WriteMessage(msgStr)
+	log.Log.WithField("a-type", "websocket").
+		WithField("b-type", c.manager.GetTypes()).
+		WithField("b-type", "send-message").
+		Infof("<user-id:%d><action:%s> %s",
+			c.GetUserId(),
+			act.Action,
+			msgStr)
+	if err != nil {
+		exceptions.Handler(err)
+		// 发送失败，close
+		c.close()
+		return false
+	}
+	switch act.Action {
+	case MoreThanOne, OtherLogin:
+		c.close()
+		return false
+	case ReceiveMessageAction:
+		msg, ok := act.Data.(*models.Message)
+		if ok {
+			c.sent(msg)
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"ws/app/chat"
+	"ws/app/models"
+	"ws/app/repositories"
+	"ws/app/resource"
+)
+
+// 预计等待时长的计算参数
+// 平均等待时长取最近接入的会话，在线客服和空闲名额按队列分别统计
+type waitEstimator struct {
+	average int64
+	admins  []*models.Admin
+	// 队列id => 在线客服数和空闲名额
+	online map[int64]int
+	spare  map[int64]int
+}
+
+func newWaitEstimator(gid int64) *waitEstimator {
+	estimator := &waitEstimator{
+		average: chat.ManualService.GetAverageWaitTime(gid),
+		admins:  make([]*models.Admin, 0),
+		online:  make(map[int64]int),
+		spare:   make(map[int64]int),
+	}
+	// 离开和忙碌的客服不会接入新用户
+	ids := AdminManager.GetOnlineIdsByStatus(gid, models.AdminStatusOnline, models.AdminStatusInvisible)
+	if len(ids) > 0 {
+		estimator.admins = repositories.AdminRepo.Get([]*repositories.Where{
+			{
+				Filed: "id in ?",
+				Value: ids,
+			},
+		}, -1, []string{}, []string{})
+	}
+	return estimator
+}
+
+func (estimator *waitEstimator) count(queueId int64) (int, int) {
+	if online, ok := estimator.online[queueId]; ok {
+		return online, estimator.spare[queueId]
+	}
+	online, spare := 0, 0
+	// 默认队列所有客服都可以接入，指定队列一次查出成员客服
+	var members map[int64]struct{}
+	if queueId > 0 && len(estimator.admins) > 0 {
+		members = make(map[int64]struct{})
+		for _, id := range repositories.ChatQueueRepo.GetAdminIds(queueId) {
+			members[id] = struct{}{}
+		}
+	}
+	for _, admin := range estimator.admins {
+		if members != nil {
+			if _, ok := members[admin.GetPrimaryKey()]; !ok {
+				continue
+			}
+		}
+		online++
+		max := chat.AdminService.GetMaxChats(admin)
+		if max <= 0 {
+			// 不限制接待人数时视为有一个空闲名额
+			spare++
+			continue
+		}
+		if n := max - chat.AdminService.GetActiveCount(admin.GetPrimaryKey()); n > 0 {
+			spare += n
+		}
+	}
+	estimator.online[queueId] = online
+	estimator.spare[queueId] = spare
+	return online, spare
+}
+
+// 获取排队位置和预计等待时长，ahead为前面等待的人数
+func (estimator *waitEstimator) location(queueId int64, ahead int64) *resource.QueueLocation {
+	online, spare := estimator.count(queueId)
+	waitTime := chat.ManualService.EstimateWaitTime(estimator.average, ahead, online, spare)
+	location := &resource.QueueLocation{
+		Count:       ahead,
+		WaitTime:    waitTime,
+		WaitMinutes: -1,
+	}
+	if waitTime >= 0 {
+		location.WaitMinutes = (waitTime + 59) / 60
+		if location.WaitMinutes == 0 {
+			location.WaitMinutes = 1
+		}
+	}
+	return location
+}
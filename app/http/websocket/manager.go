@@ -3,27 +3,32 @@ package websocket
 import (
 	"context"
 	"fmt"
-	"github.com/go-redis/redis/v8"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
+	"ws/app/chat"
 	"ws/app/contract"
 	"ws/app/databases"
 	"ws/app/models"
+	"ws/app/repositories"
 	rpcClient "ws/app/rpc/client"
 	"ws/config"
+
+	"github.com/duke-git/lancet/v2/slice"
 )
 
 // ConnContainer 管理相关方法
 type ConnContainer interface {
 	AddConn(conn Conn)
-	GetConn(user contract.User) (Conn, bool)
+	GetConn(user contract.User) ([]Conn, bool)
 	NoticeRepeatConnect(user contract.User, oldUuid string)
 	GetAllConn(gid int64) []Conn
 	GetOnlineTotal(gid int64) int64
 	ConnExist(user contract.User) bool
 	Register(connect Conn)
 	Unregister(connect Conn)
-	RemoveConn(user contract.User)
+	RemoveConn(conn Conn) bool
 	IsOnline(user contract.User) bool
 	IsLocalOnline(user contract.User) bool
 	GetOnlineUserIds(gid int64) []int64
@@ -32,9 +37,12 @@ type ConnContainer interface {
 
 type ServiceManager interface {
 	getUserServerKey(uid int64) string
+	getLegacyUserServerKey(uid int64) string
 	setUserServer(uid int64)
 	removeUserServer(uid int64)
-	getUserServer(uid int64) string
+	pruneUserServer(uid int64, server string)
+	getUserServers(uid int64) []string
+	getRemoteServers(uid int64) []string
 	getServer() string
 	isCluster() bool
 }
@@ -43,11 +51,13 @@ type ConnManager interface {
 	ConnContainer
 	ServiceManager
 	Run()
+	Drain(ctx context.Context)
+	IsDraining() bool
 	Destroy()
-	Ping()
 	SendAction(act *Action, conn ...Conn)
 	ReceiveMessage(cm *ConnMessage)
 	GetTypes() string
+	GetMetrics() *DeliverMetrics
 }
 
 type MessageHandle interface {
@@ -64,41 +74,79 @@ type ConnMessage struct {
 	Action *Action
 }
 type Shard struct {
-	m     map[int64]Conn
+	m     map[int64]map[string]Conn // user_id => uuid => conn，同一用户可以有多个连接
 	mutex sync.RWMutex
 }
 
+// GetAll 获取所有连接
 func (s *Shard) GetAll() []Conn {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 	conns := make([]Conn, 0, len(s.m))
-	for _, conn := range s.m {
-		conns = append(conns, conn)
+	for _, userConns := range s.m {
+		for _, conn := range userConns {
+			conns = append(conns, conn)
+		}
 	}
 	return conns
 }
 
+// GetTotalCount 在线用户数量
 func (s *Shard) GetTotalCount() int64 {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 	return int64(len(s.m))
 }
 
-func (s *Shard) Get(uid int64) (conn Conn, exist bool) {
+// GetConnCount 连接数量
+func (s *Shard) GetConnCount() int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var count int64
+	for _, userConns := range s.m {
+		count += int64(len(userConns))
+	}
+	return count
+}
+
+// Get 获取用户的所有连接
+func (s *Shard) Get(uid int64) (conns []Conn, exist bool) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
-	conn, exist = s.m[uid]
+	userConns, exist := s.m[uid]
+	conns = make([]Conn, 0, len(userConns))
+	for _, conn := range userConns {
+		conns = append(conns, conn)
+	}
 	return
 }
 func (s *Shard) Set(conn Conn) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	s.m[conn.GetUserId()] = conn
+	userConns, exist := s.m[conn.GetUserId()]
+	if !exist {
+		userConns = make(map[string]Conn)
+		s.m[conn.GetUserId()] = userConns
+	}
+	userConns[conn.GetUuid()] = conn
 }
-func (s *Shard) Remove(uid int64) {
+
+// Remove 移除连接，返回连接是否存在
+func (s *Shard) Remove(conn Conn) bool {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	delete(s.m, uid)
+	userConns, exist := s.m[conn.GetUserId()]
+	if !exist {
+		return false
+	}
+	if existConn, ok := userConns[conn.GetUuid()]; !ok || existConn != conn {
+		return false
+	}
+	delete(userConns, conn.GetUuid())
+	if len(userConns) == 0 {
+		delete(s.m, conn.GetUserId())
+	}
+	return true
 }
 
 type manager struct {
@@ -109,11 +157,18 @@ type manager struct {
 	onRegister   ManagerHook       //conn连接成功hook
 	onUnRegister ManagerHook       //conn连接断开hook
 	types        string            //类型
+	metrics      DeliverMetrics    // 消息投递统计
+	draining     int32             // 是否正在停机排空连接
 }
 
 func (m *manager) GetTypes() string {
 	return m.types
 }
+
+// GetMetrics 消息投递统计
+func (m *manager) GetMetrics() *DeliverMetrics {
+	return &m.metrics
+}
 func (m *manager) Do(clusterFunc func(), single func()) {
 	if m.isCluster() {
 		if clusterFunc != nil {
@@ -142,38 +197,77 @@ func (m *manager) getServer() string {
 	return m.ipAddr
 }
 
-// 获取用户server cache key
+// 获取用户server cache key，用户可能同时连接在多个server上
 func (m *manager) getUserServerKey(uid int64) string {
+	return fmt.Sprintf("%s:%d:servers", m.GetTypes(), uid)
+}
+
+// 旧版本只记录用户最后连接的server，滚动发布期间旧版本节点仍读取该key，需同时维护
+func (m *manager) getLegacyUserServerKey(uid int64) string {
 	return fmt.Sprintf("%s:%d:service", m.GetTypes(), uid)
 }
 
-// 设置用户所在server为当前server
+// 添加当前server到用户所在server集合
 func (m *manager) setUserServer(uid int64) {
 	m.Do(func() {
 		ctx := context.Background()
 		key := m.getUserServerKey(uid)
-		databases.Redis.Set(ctx, key, m.getServer(), time.Hour*24*2)
+		databases.Redis.SAdd(ctx, key, m.getServer())
+		databases.Redis.Expire(ctx, key, time.Hour*24*2)
+		databases.Redis.Set(ctx, m.getLegacyUserServerKey(uid), m.getServer(), time.Hour*24*2)
 	}, nil)
 }
 
-// 移除用户所在channel
+// 从用户所在server集合中移除当前server
 func (m *manager) removeUserServer(uid int64) {
 	m.Do(func() {
-		ctx := context.Background()
-		key := m.getUserServerKey(uid)
-		databases.Redis.Del(ctx, key)
+		m.pruneUserServer(uid, m.getServer())
 	}, nil)
 }
 
-// 获取用户channel
-func (m *manager) getUserServer(uid int64) string {
+// 从用户所在server集合中移除指定server
+// 旧key指向该server时改为指向剩余的server
+func (m *manager) pruneUserServer(uid int64, server string) {
 	ctx := context.Background()
 	key := m.getUserServerKey(uid)
-	cmd := databases.Redis.Get(ctx, key)
-	if cmd.Err() == redis.Nil {
-		return ""
+	databases.Redis.SRem(ctx, key, server)
+	legacyKey := m.getLegacyUserServerKey(uid)
+	if databases.Redis.Get(ctx, legacyKey).Val() != server {
+		return
+	}
+	remain := databases.Redis.SRandMember(ctx, key).Val()
+	if remain == "" {
+		databases.Redis.Del(ctx, legacyKey)
+	} else {
+		databases.Redis.Set(ctx, legacyKey, remain, time.Hour*24*2)
+	}
+}
+
+// 获取用户所在的所有server，包含旧版本节点记录的server
+func (m *manager) getUserServers(uid int64) []string {
+	ctx := context.Background()
+	key := m.getUserServerKey(uid)
+	cmd := databases.Redis.SMembers(ctx, key)
+	if cmd.Err() != nil {
+		return []string{}
+	}
+	servers := cmd.Val()
+	legacy := databases.Redis.Get(ctx, m.getLegacyUserServerKey(uid)).Val()
+	if legacy != "" && !slice.Contain(servers, legacy) {
+		servers = append(servers, legacy)
+	}
+	return servers
+}
+
+// 获取用户所在的除当前server外的其他server
+func (m *manager) getRemoteServers(uid int64) []string {
+	servers := make([]string, 0)
+	for _, server := range m.getUserServers(uid) {
+		if server != m.getServer() {
+			servers = append(servers, server)
+		}
 	}
-	return cmd.Val()
+	return servers
 }
 
 // ReceiveMessage 接受消息
@@ -182,21 +276,22 @@ func (m *manager) ReceiveMessage(cm *ConnMessage) {
 }
 
 // NoticeRepeatConnect 重复链接
+// 单端登录时通知用户的其他连接断开
 func (m *manager) NoticeRepeatConnect(user contract.User, newUuid string) {
+	m.NoticeLocalRepeatConnect(user, newUuid)
 	m.Do(func() {
-		oldServer := m.getUserServer(user.GetPrimaryKey())
-		if oldServer != "" {
-			rpcClient.NoticeRepeatConnect(user.GetPrimaryKey(), m.GetTypes(), newUuid, oldServer)
+		for _, server := range m.getRemoteServers(user.GetPrimaryKey()) {
+			rpcClient.NoticeRepeatConnect(user.GetPrimaryKey(), m.GetTypes(), newUuid, server)
 		}
-	}, func() {
-		m.NoticeLocalRepeatConnect(user, newUuid)
-	})
+	}, nil)
 }
 
 func (m *manager) NoticeLocalRepeatConnect(user contract.User, newUuid string) {
-	oldConn, ok := m.GetConn(user)
-	if ok && oldConn.GetUuid() != newUuid {
-		m.SendAction(NewMoreThanOne(), oldConn)
+	conns, _ := m.GetConn(user)
+	for _, conn := range conns {
+		if conn.GetUuid() != newUuid {
+			m.SendAction(NewMoreThanOne(), conn)
+		}
 	}
 }
 
@@ -212,8 +307,13 @@ func (m *manager) GetLocalOnlineUserIds(gid int64) []int64 {
 	s := m.getSpread(gid)
 	allConn := s.GetAll()
 	ids := make([]int64, 0)
+	exist := make(map[int64]struct{})
 	for _, conn := range allConn {
+		if _, ok := exist[conn.GetUserId()]; ok {
+			continue
+		}
 		if conn.GetGroupId() == gid {
+			exist[conn.GetUserId()] = struct{}{}
 			ids = append(ids, conn.GetUserId())
 		}
 	}
@@ -236,15 +336,17 @@ func (m *manager) GetOnlineTotal(gid int64) int64 {
 
 // IsOnline 用户是否在线
 func (m *manager) IsOnline(user contract.User) bool {
+	if m.IsLocalOnline(user) {
+		return true
+	}
 	if m.isCluster() {
-		server := m.getUserServer(user.GetPrimaryKey())
-		if server != "" {
-			return rpcClient.ConnectionOnline(user.GetPrimaryKey(), m.GetTypes(), server)
+		for _, server := range m.getRemoteServers(user.GetPrimaryKey()) {
+			if rpcClient.ConnectionOnline(user.GetPrimaryKey(), m.GetTypes(), server) {
+				return true
+			}
 		}
-		return false
-	} else {
-		return m.IsLocalOnline(user)
 	}
+	return false
 }
 
 func (m *manager) IsLocalOnline(user contract.User) bool {
@@ -264,10 +366,10 @@ func (m *manager) ConnExist(user contract.User) bool {
 	return exist
 }
 
-// GetConn 获取客户端
-func (m *manager) GetConn(user contract.User) (client Conn, ok bool) {
+// GetConn 获取用户在当前server上的所有客户端
+func (m *manager) GetConn(user contract.User) (clients []Conn, ok bool) {
 	s := m.getSpread(user.GetGroupId())
-	client, ok = s.Get(user.GetPrimaryKey())
+	clients, ok = s.Get(user.GetPrimaryKey())
 	return
 }
 
@@ -278,9 +380,9 @@ func (m *manager) AddConn(conn Conn) {
 }
 
 // RemoveConn 移除客户端
-func (m *manager) RemoveConn(user contract.User) {
-	s := m.getSpread(user.GetGroupId())
-	s.Remove(user.GetPrimaryKey())
+func (m *manager) RemoveConn(conn Conn) bool {
+	s := m.getSpread(conn.GetGroupId())
+	return s.Remove(conn)
 }
 
 // GetAllConn 获取所有客户端
@@ -291,8 +393,8 @@ func (m *manager) GetAllConn(groupId int64) (conns []Conn) {
 
 func (m *manager) GetAllConnCount() int64 {
 	var count int64
-	for gid := range m.shard {
-		count += m.GetLocalOnlineTotal(int64(gid))
+	for _, s := range m.shard {
+		count += s.GetConnCount()
 	}
 	return count
 }
@@ -305,26 +407,55 @@ func (m *manager) GetTotalConn() []Conn {
 	return conns
 }
 
+// 用户除conn外是否还有其他连接(包括其他server上的连接)
+func (m *manager) hasOtherConn(conn Conn) bool {
+	conns, _ := m.GetConn(conn.GetUser())
+	for _, c := range conns {
+		if c != conn {
+			return true
+		}
+	}
+	if m.isCluster() {
+		// 异常退出的server不会清理记录，需确认连接仍存在，并移除已失效的server
+		online := false
+		for _, server := range m.getRemoteServers(conn.GetUserId()) {
+			if rpcClient.ConnectionOnline(conn.GetUserId(), m.GetTypes(), server) {
+				online = true
+			} else {
+				m.pruneUserServer(conn.GetUserId(), server)
+			}
+		}
+		return online
+	}
+	return false
+}
+
 // Unregister 客户端注销
+// 用户最后一个连接断开时才执行onUnRegister
 func (m *manager) Unregister(conn Conn) {
-	s := m.getSpread(conn.GetGroupId())
-	existConn, exist := s.Get(conn.GetUserId())
-	if exist {
-		if existConn == conn {
-			m.RemoveConn(conn.GetUser())
-			if m.onUnRegister != nil {
-				m.onUnRegister(conn)
-			}
+	if m.RemoveConn(conn) {
+		if !m.IsLocalOnline(conn.GetUser()) {
+			m.removeUserServer(conn.GetUserId())
+		}
+		if !m.hasOtherConn(conn) && m.onUnRegister != nil {
+			m.onUnRegister(conn)
 		}
 	}
 }
 
 // Register 客户端注册
-// 先处理是否重复连接
+// 排空连接期间直接关闭新连接
+// 分组开启单端登录时，先断开用户的其他连接
 // 集群模式下，如果不在本机则投递一个消息
 func (m *manager) Register(conn Conn) {
+	if m.IsDraining() {
+		conn.close()
+		return
+	}
 	timer := time.After(1 * time.Second)
-	m.NoticeRepeatConnect(conn.GetUser(), conn.GetUuid())
+	if chat.SettingService.GetIsSingleSession(conn.GetGroupId()) {
+		m.NoticeRepeatConnect(conn.GetUser(), conn.GetUuid())
+	}
 	m.AddConn(conn)
 	m.setUserServer(conn.GetUserId())
 	conn.run()
@@ -334,39 +465,86 @@ func (m *manager) Register(conn Conn) {
 	}
 }
 
-// Ping
-// 给所有客户端发送心跳
-// 客户端因意外断开链接，服务器没有关闭事件，无法得知连接已关闭
-// 通过心跳发送""字符串，如果发送失败，则调用conn的close方法执行清理
-func (m *manager) Ping() {
-	ticker := time.NewTicker(time.Second * 10)
-	for {
-		select {
-		case <-ticker.C:
-			ping := NewPing()
-			for _, s := range m.shard {
-				conns := s.GetAll()
-				m.SendAction(ping, conns...)
-			}
-		}
-	}
-}
-
 func (m *manager) Run() {
 	m.shard = make([]*Shard, m.shardCount, m.shardCount)
 	var i int64
 	for i = 0; i < m.shardCount; i++ {
 		m.shard[i] = &Shard{
-			m:     make(map[int64]Conn),
+			m:     make(map[int64]map[string]Conn),
 			mutex: sync.RWMutex{},
 		}
 	}
-	go m.Ping()
+}
+
+// 排空连接时检查剩余连接的间隔
+const drainCheckPeriod = 200 * time.Millisecond
+
+// 客户端重连的最大随机延迟(毫秒)，避免所有客户端同时重连到其他server
+const reconnectJitter = 5000
+
+// IsDraining 是否正在排空连接，排空期间不再接受新连接
+func (m *manager) IsDraining() bool {
+	return atomic.LoadInt32(&m.draining) == 1
+}
+
+// Drain 停机前排空连接
+// 清除当前server的用户server记录，使消息路由到其他server
+// 通知客户端服务重启并在随机延迟后重连，等待客户端断开或ctx超时
+func (m *manager) Drain(ctx context.Context) {
+	if !atomic.CompareAndSwapInt32(&m.draining, 0, 1) {
+		return
+	}
+	conns := m.GetTotalConn()
+	for _, conn := range conns {
+		m.removeUserServer(conn.GetUserId())
+	}
+	// 每个server使用不同的随机序列
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for _, conn := range conns {
+		conn.Deliver(NewServerRestarting(r.Int63n(reconnectJitter)))
+	}
+	ticker := time.NewTicker(drainCheckPeriod)
+	defer ticker.Stop()
+	for m.GetAllConnCount() > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 // Destroy
-// 释放相关资源
+// 释放相关资源，关闭剩余的连接
 func (m *manager) Destroy() {
-	m.Do(func() {
-	}, nil)
+	atomic.StoreInt32(&m.draining, 1)
+	for _, conn := range m.GetTotalConn() {
+		m.removeUserServer(conn.GetUserId())
+		conn.close()
+	}
+}
+
+// 补发msgId之后的消息，每次最多补发syncLimit条
+// 补发完成后发送sync-result，还有未补发的消息时客户端以last_id继续sync
+// msgId为0时客户端没有历史消息，不补发
+func syncMessages(conn Conn, msgId int64, wheres []*repositories.Where) {
+	if msgId == 0 {
+		conn.Deliver(NewSyncResult(0, false))
+		return
+	}
+	wheres = append(wheres, &repositories.Where{
+		Filed: "id > ?",
+		Value: msgId,
+	})
+	messages := repositories.MessageRepo.Get(wheres, syncLimit+1, []string{"User", "Admin", "Reply"}, []string{"id"})
+	hasMore := len(messages) > syncLimit
+	if hasMore {
+		messages = messages[:syncLimit]
+	}
+	lastId := msgId
+	for _, msg := range messages {
+		conn.Deliver(NewReceiveAction(msg))
+		lastId = msg.Id
+	}
+	conn.Deliver(NewSyncResult(lastId, hasMore))
 }
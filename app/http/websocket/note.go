@@ -0,0 +1,29 @@
+package websocket
+
+import (
+	"ws/app/models"
+	"ws/app/repositories"
+	rpcClient "ws/app/rpc/client"
+)
+
+// BroadcastNote 通知分组内的其他客服有新的内部备注
+func (m *adminManager) BroadcastNote(note *models.Note) {
+	m.Do(func() {
+		rpcClient.BroadcastNote(note.Id)
+	}, func() {
+		m.BroadcastLocalNote(note)
+	})
+}
+
+func (m *adminManager) BroadcastLocalNote(note *models.Note) {
+	if note.Admin == nil {
+		note.Admin = repositories.AdminRepo.FirstById(note.AdminId)
+	}
+	act := NewNoteCreated(note.ToJson())
+	for _, conn := range m.GetAllConn(note.GroupId) {
+		// 不通知作者
+		if conn.GetUserId() != note.AdminId {
+			m.SendAction(act, conn)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package websocket
+
+import (
+	"sync"
+	"sync/atomic"
+	"ws/config"
+)
+
+// 可合并的广播类action，队列中只需保留最新的一条
+var coalesceActions = map[string]struct{}{
+	WaitingUserAction:   {},
+	WaitingUserCount:    {},
+	QueueLocationAction: {},
+	AdminsAction:        {},
+}
+
+type pushResult int
+
+const (
+	pushOk pushResult = iota
+	pushCoalesced
+	pushDropped
+	pushOverflow
+)
+
+// 有界发送队列，写入不阻塞
+type sendQueue struct {
+	mutex    sync.Mutex
+	items    []*Action
+	size     int
+	strategy string
+	notify   chan struct{} // 有新消息时通知发送goroutine
+}
+
+func newSendQueue(size int, strategy string) *sendQueue {
+	return &sendQueue{
+		items:    make([]*Action, 0, size),
+		size:     size,
+		strategy: strategy,
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+// 写入队列
+func (q *sendQueue) push(act *Action) (result pushResult) {
+	q.mutex.Lock()
+	defer func() {
+		q.mutex.Unlock()
+		if result != pushOverflow {
+			select {
+			case q.notify <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	if _, ok := coalesceActions[act.Action]; ok {
+		for i, item := range q.items {
+			if item.Action == act.Action {
+				q.items[i] = act
+				return pushCoalesced
+			}
+		}
+	}
+	if len(q.items) >= q.size {
+		if q.strategy == config.OverflowDisconnect {
+			return pushOverflow
+		}
+		q.items[0] = nil
+		q.items = append(q.items[1:], act)
+		return pushDropped
+	}
+	q.items = append(q.items, act)
+	return pushOk
+}
+
+// 取出队列中所有消息
+func (q *sendQueue) popAll() []*Action {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	items := q.items
+	q.items = make([]*Action, 0, q.size)
+	return items
+}
+
+// DeliverMetrics 消息投递统计
+type DeliverMetrics struct {
+	Dropped      int64 // 队列满被丢弃的消息数
+	Coalesced    int64 // 被合并的广播消息数
+	Disconnected int64 // 队列满被断开的连接数
+}
+
+func (m *DeliverMetrics) incr(result pushResult) {
+	switch result {
+	case pushCoalesced:
+		atomic.AddInt64(&m.Coalesced, 1)
+	case pushDropped:
+		atomic.AddInt64(&m.Dropped, 1)
+	case pushOverflow:
+		atomic.AddInt64(&m.Disconnected, 1)
+	}
+}
+
+// Snapshot 当前统计值
+func (m *DeliverMetrics) Snapshot() DeliverMetrics {
+	return DeliverMetrics{
+		Dropped:      atomic.LoadInt64(&m.Dropped),
+		Coalesced:    atomic.LoadInt64(&m.Coalesced),
+		Disconnected: atomic.LoadInt64(&m.Disconnected),
+	}
+}
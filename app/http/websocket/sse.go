@@ -0,0 +1,140 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"ws/app/contract"
+	rpcClient "ws/app/rpc/client"
+)
+
+// SseTransport sse传输，用于无法使用websocket的网络环境
+// 下行消息通过text/event-stream推送，上行消息通过POST提交后交给ReadMessage
+type SseTransport struct {
+	writer  http.ResponseWriter
+	flusher http.Flusher
+	receive chan []byte   // POST提交的消息
+	done    chan struct{} // 连接断开后关闭
+	mutex   sync.Mutex
+	closed  bool
+}
+
+// NewSseConn 建立sse连接
+// 写入响应头并推送connected事件，客户端需使用事件中的uuid提交上行消息
+func NewSseConn(user contract.User, w http.ResponseWriter, manager ConnManager) (Conn, *SseTransport, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, nil, errors.New("streaming unsupported")
+	}
+	t := &SseTransport{
+		writer:  w,
+		flusher: flusher,
+		receive: make(chan []byte, 10),
+		done:    make(chan struct{}),
+	}
+	client := newClient(user, t, manager, jsonCodec{})
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	err := t.write(fmt.Sprintf("event: connected\ndata: {\"uuid\":\"%s\"}\n\n", client.GetUuid()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, t, nil
+}
+
+// ReadMessage 读取POST提交的消息
+func (t *SseTransport) ReadMessage() ([]byte, error) {
+	select {
+	case b := <-t.receive:
+		return b, nil
+	case <-t.done:
+		return nil, errors.New("connection closed")
+	}
+}
+
+func (t *SseTransport) WriteMessage(b []byte) error {
+	return t.write("data: " + string(b) + "\n\n")
+}
+
+// Ping 发送注释行，防止代理因空闲断开连接
+func (t *SseTransport) Ping() error {
+	return t.write(": ping\n\n")
+}
+
+func (t *SseTransport) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.done)
+	}
+	return nil
+}
+
+// Wait 阻塞直到客户端断开或连接被关闭，http handler返回前必须调用
+func (t *SseTransport) Wait(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-t.done:
+	}
+	_ = t.Close()
+}
+
+func (t *SseTransport) write(s string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return errors.New("connection closed")
+	}
+	_, err := t.writer.Write([]byte(s))
+	if err != nil {
+		return err
+	}
+	t.flusher.Flush()
+	return nil
+}
+
+// 接收POST提交的消息，不阻塞调用方
+func (t *SseTransport) push(b []byte) error {
+	select {
+	case <-t.done:
+		return errors.New("连接已断开")
+	default:
+	}
+	select {
+	case t.receive <- b:
+		return nil
+	default:
+		return errors.New("发送过于频繁，请慢一些")
+	}
+}
+
+// ReceiveSseMessage 将POST提交的消息交给用户对应的sse连接
+// 连接不在本机时，集群模式下转发到用户所在的其他server
+func (m *manager) ReceiveSseMessage(user contract.User, uuid string, b []byte, isRemote bool) error {
+	conns, _ := m.GetConn(user)
+	for _, conn := range conns {
+		if conn.GetUuid() != uuid {
+			continue
+		}
+		if client, ok := conn.(*Client); ok {
+			if t, ok := client.transport.(*SseTransport); ok {
+				return t.push(b)
+			}
+		}
+	}
+	if !isRemote && m.isCluster() {
+		for _, server := range m.getRemoteServers(user.GetPrimaryKey()) {
+			if rpcClient.SendSseMessage(user.GetPrimaryKey(), m.GetTypes(), uuid, b, server) == nil {
+				return nil
+			}
+		}
+	}
+	return errors.New("连接不存在")
+}
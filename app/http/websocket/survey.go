@@ -0,0 +1,69 @@
+package websocket
+
+import (
+	"errors"
+	"ws/app/chat"
+	"ws/app/models"
+	"ws/app/repositories"
+)
+
+// SendSurvey 会话结束后向用户发送满意度评价，每个会话只发送一次
+func (userManager *userManager) SendSurvey(sessionId uint64) {
+	session := repositories.ChatSessionRepo.FirstById(sessionId)
+	if session == nil || session.AcceptedAt == 0 || session.RatedAt > 0 {
+		return
+	}
+	if !chat.SettingService.GetIsSurvey(session.GroupId) {
+		return
+	}
+	exist := repositories.MessageRepo.First([]*repositories.Where{
+		{
+			Filed: "session_id = ?",
+			Value: session.Id,
+		},
+		{
+			Filed: "type = ?",
+			Value: models.TypeSurvey,
+		},
+	}, []string{})
+	if exist != nil {
+		return
+	}
+	msg := repositories.MessageRepo.NewNotice(session, "请对本次服务进行评价")
+	msg.Type = models.TypeSurvey
+	repositories.MessageRepo.Save(msg)
+	userManager.DeliveryMessage(msg, false)
+}
+
+// SubmitSurvey 用户提交满意度评价，评价保存到评价消息对应的会话
+func (userManager *userManager) SubmitSurvey(conn Conn, msgId int64, rating int8, comment string) error {
+	msg := repositories.MessageRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: msgId,
+		},
+		{
+			Filed: "user_id = ?",
+			Value: conn.GetUserId(),
+		},
+		{
+			Filed: "type = ?",
+			Value: models.TypeSurvey,
+		},
+	}, []string{})
+	if msg == nil {
+		return errors.New("评价不存在")
+	}
+	session := repositories.ChatSessionRepo.FirstById(msg.SessionId)
+	if session == nil {
+		return errors.New("会话不存在")
+	}
+	err := chat.SessionService.Rate(session, rating, comment)
+	if err != nil {
+		return err
+	}
+	notice := repositories.MessageRepo.NewNotice(session, "感谢您的评价")
+	repositories.MessageRepo.Save(notice)
+	userManager.DeliveryMessage(notice, false)
+	return nil
+}
@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"github.com/gorilla/websocket"
+	"time"
+)
+
+// Transport 连接的传输层
+// Client负责验证、限流、投递等逻辑，Transport只负责读写
+type Transport interface {
+	ReadMessage() ([]byte, error) // 阻塞读取一条消息，返回错误说明连接已断开
+	WriteMessage(b []byte) error
+	Ping() error // 心跳，用于探测断开的连接
+	Close() error
+}
+
+// websocket传输
+type wsTransport struct {
+	conn        *websocket.Conn
+	messageType int // 写入的帧类型，由codec决定
+}
+
+func newWsTransport(conn *websocket.Conn, codec Codec) *wsTransport {
+	conn.SetReadLimit(MaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	// 对端回复的pong会延长读超时
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	return &wsTransport{
+		conn:        conn,
+		messageType: codec.MessageType(),
+	}
+}
+
+// ReadMessage 超过pongWait未收到任何帧则读超时
+func (t *wsTransport) ReadMessage() ([]byte, error) {
+	_, b, err := t.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	_ = t.conn.SetReadDeadline(time.Now().Add(pongWait))
+	return b, nil
+}
+
+func (t *wsTransport) WriteMessage(b []byte) error {
+	_ = t.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return t.conn.WriteMessage(t.messageType, b)
+}
+
+// Ping 发送ping控制帧
+func (t *wsTransport) Ping() error {
+	_ = t.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return t.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+func (t *wsTransport) Close() error {
+	return t.conn.Close()
+}
@@ -1,7 +1,9 @@
 package websocket
 
 import (
+	"errors"
 	"github.com/duke-git/lancet/v2/netutil"
+	"github.com/duke-git/lancet/v2/random"
 	"github.com/silenceper/wechat/v2/miniprogram/subscribe"
 	"github.com/spf13/viper"
 	"strconv"
@@ -46,34 +48,50 @@ func (userManager *userManager) Run() {
 }
 
 // DeliveryMessage 投递消息
-// 查询user是否在本机上，是则直接投递
-// 查询user当前server，如果存在则投递到该channel上
-// 最后则说明user不在线，处理相关逻辑
-// remote 是否从消息队列读取的消息
+// user在本机上的连接直接投递
+// 集群模式下再投递到user所在的其他server上
+// 最后如果user不在线，处理相关逻辑
+// remote 是否从其他server投递过来的消息
 func (userManager *userManager) DeliveryMessage(msg *models.Message, isRemote bool) {
-	userConn, exist := UserManager.GetConn(msg.GetUser())
+	userConns, exist := UserManager.GetConn(msg.GetUser())
 	if exist {
-		userConn.Deliver(NewReceiveAction(msg))
+		userManager.SendAction(NewReceiveAction(msg), userConns...)
+	}
+	if isRemote {
+		if !exist { // 记录的server上已没有user的连接
+			userManager.removeUserServer(msg.UserId)
+		}
 		return
-	} else if !isRemote && userManager.isCluster() {
-		server := userManager.getUserServer(msg.UserId)
-		if server != "" {
+	}
+	if userManager.isCluster() {
+		servers := userManager.getRemoteServers(msg.UserId)
+		for _, server := range servers {
 			rpcClient.SendMessage(msg.Id, server)
-			return
 		}
+		exist = exist || len(servers) > 0
+	}
+	if !exist {
+		userManager.handleOffline(msg)
 	}
-	userManager.handleOffline(msg)
 }
 
 // NoticeQueueLocation 等待人数
 func (userManager *userManager) NoticeQueueLocation(conn Conn) {
+	userManager.noticeQueueLocation(conn, newWaitEstimator(conn.GetGroupId()))
+}
+
+func (userManager *userManager) noticeQueueLocation(conn Conn, estimator *waitEstimator) {
 	log.Log.WithField("type", "WEBSOCKET").
 		Infof("<user><notice><waiting-count><user-id:%d>", conn.GetGroupId())
 	uid := conn.GetUserId()
+	// 只计算同一队列中排在前面的用户
+	queueId := chat.ManualService.GetQueueId(uid, conn.GetGroupId())
 	uTime := chat.ManualService.GetTime(uid, conn.GetGroupId())
-	count := chat.ManualService.GetCountByTime(conn.GetGroupId(), "-inf",
+	count := chat.ManualService.GetQueueCountByTime(conn.GetGroupId(), queueId, "-inf",
 		strconv.FormatFloat(uTime, 'f', 0, 64))
+	// 保留等待人数的action兼容旧版客户端
 	conn.Deliver(NewWaitingUserCount(count - 1))
+	conn.Deliver(NewQueueLocation(estimator.location(queueId, count-1)))
 }
 
 func (userManager *userManager) BroadcastQueueLocation(gid int64) {
@@ -91,8 +109,15 @@ func (userManager *userManager) BroadcastLocalQueueLocation(gid int64) {
 	log.Log.WithField("type", "WEBSOCKET").
 		Infof("<user><broadcast><waiting-count><group-id:%d>", gid)
 	conns := userManager.GetAllConn(gid)
+	// 每次广播重新计算，同一次广播中共用，没有排队中的用户时不计算
+	var estimator *waitEstimator
 	for _, conn := range conns {
-		userManager.NoticeQueueLocation(conn)
+		if chat.ManualService.IsIn(conn.GetUserId(), gid) {
+			if estimator == nil {
+				estimator = newWaitEstimator(gid)
+			}
+			userManager.noticeQueueLocation(conn, estimator)
+		}
 	}
 }
 
@@ -146,6 +171,10 @@ func (userManager *userManager) handleMessage(payload *ConnMessage) {
 				msg.ReceivedAT = time.Now().Unix()
 				msg.User = conn.GetUser().(*models.User)
 				msg.AdminId = chat.UserService.GetValidAdmin(conn.GetUserId())
+				if err := chat.MessageService.ValidateReply(msg); err != nil {
+					conn.Deliver(NewErrorMessage(err.Error()))
+					return
+				}
 				// 发送回执
 				_ = repositories.MessageRepo.Save(msg)
 				conn.Deliver(NewReceiptAction(msg))
@@ -169,15 +198,26 @@ func (userManager *userManager) handleMessage(payload *ConnMessage) {
 							}
 							repositories.MessageRepo.Save(msg)
 							AdminManager.BroadcastWaitingUser(conn.GetGroupId())
+						} else if !chat.BusinessHoursService.IsOpen(conn.GetGroupId()) { // 非营业时间
+							repositories.MessageRepo.Save(msg)
+							// 优先匹配非营业时间的规则，没有匹配时自动转人工会回复非营业时间提示
+							if !userManager.triggerMessageEvent(models.SceneClosed, msg) {
+								if chat.SettingService.GetIsAutoTransferManual(conn.GetGroupId()) {
+									userManager.addToManual(conn.GetUser(), 0)
+								} else {
+									userManager.triggerMessageEvent(models.SceneNotAccepted, msg)
+								}
+							}
 						} else {
 							if chat.SettingService.GetIsAutoTransferManual(conn.GetGroupId()) { // 自动转人工
-								session := UserManager.addToManual(conn.GetUser())
+								session := UserManager.addToManual(conn.GetUser(), 0)
 								if session != nil {
 									msg.SessionId = session.Id
 								}
 								repositories.MessageRepo.Save(msg)
 								AdminManager.BroadcastWaitingUser(conn.GetGroupId())
 								userManager.BroadcastQueueLocation(conn.GetGroupId())
+								go AdminManager.AutoAssign(conn.GetGroupId())
 							} else {
 								repositories.MessageRepo.Save(msg)
 								userManager.triggerMessageEvent(models.SceneNotAccepted, msg)
@@ -187,19 +227,169 @@ func (userManager *userManager) handleMessage(payload *ConnMessage) {
 				}
 			}
 		}
+	// 用户输入状态，只在有对应客服时投递
+	case TypingStartAction, TypingStopAction:
+		adminId := chat.UserService.GetValidAdmin(conn.GetUserId())
+		if adminId > 0 {
+			AdminManager.DeliveryTyping(act.Action, conn.GetUserId(), adminId, false)
+		}
+	// 用户已读消息，通知当前客服
+	case MessageReadAction:
+		msgId := repositories.MessageRepo.ReadByUser(conn.GetUserId(), act.GetMsgId())
+		if msgId > 0 {
+			adminId := chat.UserService.GetValidAdmin(conn.GetUserId())
+			if adminId > 0 {
+				AdminManager.DeliveryRead(conn.GetUserId(), adminId, msgId, false)
+			}
+		}
+	// 用户点击快捷按钮
+	case ButtonClickAction:
+		err := userManager.ButtonClick(conn, act.GetMsgId(), act.GetString("value"))
+		if err != nil {
+			conn.Deliver(NewErrorMessage(err.Error()))
+		}
+	// 用户提交满意度评价
+	case SurveyAction:
+		err := userManager.SubmitSurvey(conn, act.GetMsgId(), int8(act.GetInt("rating")), act.GetString("comment"))
+		if err != nil {
+			conn.Deliver(NewErrorMessage(err.Error()))
+		}
+	// 用户撤回消息
+	case RecallMessageAction:
+		msg, err := userManager.RecallMessage(conn.GetUserId(), act.GetMsgId())
+		if err != nil {
+			conn.Deliver(NewErrorMessage(err.Error()))
+			return
+		}
+		conn.Deliver(NewMessageRecalledAction(msg))
+	// 用户重连后补发last msg_id之后的客服及系统消息
+	case SyncAction:
+		syncMessages(conn, act.GetMsgId(), []*repositories.Where{
+			{
+				Filed: "user_id = ?",
+				Value: conn.GetUserId(),
+			},
+			{
+				Filed: "source in ?",
+				Value: []int{models.SourceAdmin, models.SourceSystem},
+			},
+		})
+	}
+}
+
+// RecallMessage user撤回自己发送的消息
+// 已接入则通知admin，未接入则刷新待接入列表
+func (userManager *userManager) RecallMessage(uid int64, msgId int64) (*models.Message, error) {
+	msg := repositories.MessageRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: msgId,
+		},
+		{
+			Filed: "user_id = ?",
+			Value: uid,
+		},
+		{
+			Filed: "source = ?",
+			Value: models.SourceUser,
+		},
+	}, []string{})
+	if msg == nil {
+		return nil, errors.New("消息不存在")
+	}
+	err := chat.MessageService.Recall(msg)
+	if err != nil {
+		return nil, err
+	}
+	if msg.AdminId > 0 {
+		AdminManager.DeliveryRecall(msg, false)
+	} else {
+		AdminManager.BroadcastWaitingUser(msg.GroupId)
+	}
+	return msg, nil
+}
+
+// DeliveryEdit
+// 通知user，admin编辑了消息
+// 集群模式下同时投递到user所在的其他server
+func (userManager *userManager) DeliveryEdit(msg *models.Message, isRemote bool) {
+	conns, _ := userManager.GetConn(msg.GetUser())
+	userManager.SendAction(NewMessageEditedAction(msg), conns...)
+	if !isRemote && userManager.isCluster() {
+		for _, server := range userManager.getRemoteServers(msg.UserId) {
+			rpcClient.SendEdit(msg.Id, server)
+		}
+	}
+}
+
+// DeliveryRecall
+// 通知user，admin撤回了消息
+// 集群模式下同时投递到user所在的其他server
+func (userManager *userManager) DeliveryRecall(msg *models.Message, isRemote bool) {
+	conns, _ := userManager.GetConn(msg.GetUser())
+	userManager.SendAction(NewMessageRecalledAction(msg), conns...)
+	if !isRemote && userManager.isCluster() {
+		for _, server := range userManager.getRemoteServers(msg.UserId) {
+			rpcClient.SendRecall(msg.Id, server)
+		}
+	}
+}
+
+// DeliveryRead
+// 通知user，admin已读到msgId
+// 集群模式下同时投递到user所在的其他server，user离线则丢弃
+func (userManager *userManager) DeliveryRead(uid int64, adminId int64, msgId int64, isRemote bool) {
+	user := repositories.UserRepo.FirstById(uid)
+	if user == nil {
+		return
+	}
+	conns, _ := userManager.GetConn(user)
+	userManager.SendAction(NewMessageReadAction(uid, adminId, msgId), conns...)
+	if !isRemote && userManager.isCluster() {
+		for _, server := range userManager.getRemoteServers(uid) {
+			rpcClient.SendRead(userManager.GetTypes(), uid, adminId, msgId, server)
+		}
+	}
+}
+
+// DeliveryTyping
+// 投递admin的输入状态给user
+// 集群模式下同时投递到user所在的其他server，user离线则丢弃
+func (userManager *userManager) DeliveryTyping(action string, uid int64, adminId int64, isRemote bool) {
+	user := repositories.UserRepo.FirstById(uid)
+	if user == nil {
+		return
+	}
+	conns, _ := userManager.GetConn(user)
+	userManager.SendAction(NewTypingAction(action, uid, adminId), conns...)
+	if !isRemote && userManager.isCluster() {
+		for _, server := range userManager.getRemoteServers(uid) {
+			rpcClient.SendTyping(userManager.GetTypes(), action, uid, adminId, server)
+		}
 	}
 }
 
 func (userManager *userManager) unRegisterHook(conn Conn) {
-	AdminManager.NoticeUserOffline(conn.GetUser())
+	// 排空连接期间客户端会重连到其他server，不通知客服用户离线
+	if !userManager.IsDraining() {
+		AdminManager.NoticeUserOffline(conn.GetUser())
+	}
+	// 排队中的用户断开连接，超过设定时长未重连则移出队列
+	if chat.ManualService.IsIn(conn.GetUserId(), conn.GetGroupId()) {
+		_ = chat.ManualService.SetOffline(conn.GetUserId(), conn.GetGroupId())
+	}
 }
 
 // 链接建立后的额外操作
+// 用户的第一个连接建立时通知客服用户上线
 // 如果已经在待接入人工列表中，则推送当前队列位置
 // 如果不在待接入人工列表中且没有设置客服，则推送欢迎语
 func (userManager *userManager) registerHook(conn Conn) {
-	AdminManager.NoticeUserOnline(conn.GetUser())
+	if !userManager.hasOtherConn(conn) {
+		AdminManager.NoticeUserOnline(conn.GetUser())
+	}
 	if chat.ManualService.IsIn(conn.GetUserId(), conn.GetGroupId()) {
+		_ = chat.ManualService.RemoveOffline(conn.GetUserId(), conn.GetGroupId())
 		userManager.NoticeQueueLocation(conn)
 	} else if chat.UserService.GetValidAdmin(conn.GetUserId()) == 0 {
 		rule := repositories.AutoRuleRepo.GetEnterByGroup(conn.GetGroupId())
@@ -215,11 +405,38 @@ func (userManager *userManager) registerHook(conn Conn) {
 	}
 }
 
+// SetQueue 通过队列名称指定用户转人工时进入的队列，name为空则进入默认队列
+func (userManager *userManager) SetQueue(user contract.User, name string) error {
+	if name == "" {
+		return chat.UserService.SetQueue(user.GetPrimaryKey(), 0)
+	}
+	queue := repositories.ChatQueueRepo.FirstByName(user.GetGroupId(), name)
+	if queue == nil {
+		return errors.New("队列不存在")
+	}
+	return chat.UserService.SetQueue(user.GetPrimaryKey(), queue.Id)
+}
+
 // 加入人工列表
-func (userManager *userManager) addToManual(user contract.User) *models.ChatSession {
+// queueId为0时进入用户通过接口指定的队列，未指定则进入默认队列
+func (userManager *userManager) addToManual(user contract.User, queueId int64) *models.ChatSession {
 	if !chat.ManualService.IsIn(user.GetPrimaryKey(), user.GetGroupId()) {
-		onlineServerCount := AdminManager.GetOnlineTotal(user.GetGroupId())
-		if onlineServerCount == 0 { // 如果没有在线客服
+		if queueId == 0 {
+			queueId = chat.UserService.GetQueue(user.GetPrimaryKey())
+		}
+		// 队列已被删除或不属于用户所在分组时进入默认队列
+		if queueId > 0 && repositories.ChatQueueRepo.FirstByGroup(user.GetGroupId(), queueId) == nil {
+			queueId = 0
+		}
+		// 非营业时间不进入队列
+		if !chat.BusinessHoursService.IsOpen(user.GetGroupId()) {
+			userManager.replyOutOfHours(user)
+			return nil
+		}
+		// 离开的客服视为离线
+		onlineServerIds := AdminManager.GetOnlineIdsByStatus(user.GetGroupId(),
+			models.AdminStatusOnline, models.AdminStatusBusy, models.AdminStatusInvisible)
+		if len(onlineServerIds) == 0 { // 如果没有在线客服
 			rule := repositories.AutoRuleRepo.GetAdminAllOffLine(user.GetGroupId())
 			if rule != nil {
 				switch rule.ReplyType {
@@ -235,13 +452,21 @@ func (userManager *userManager) addToManual(user contract.User) *models.ChatSess
 				}
 			}
 		}
-		_ = chat.ManualService.Add(user.GetPrimaryKey(), user.GetGroupId())
+		var priority int8
+		if u, ok := user.(*models.User); ok {
+			priority = u.Priority
+		}
+		_ = chat.ManualService.Add(user.GetPrimaryKey(), user.GetGroupId(), queueId, priority)
 		session := repositories.ChatSessionRepo.FirstActiveByUser(user.GetPrimaryKey(), 0)
 		if session == nil {
 			session = repositories.ChatSessionRepo.Create(user.GetPrimaryKey(),
 				user.GetGroupId(),
 				models.ChatSessionTypeNormal)
 		}
+		session.QueueId = queueId
+		// 排队超时从加入队列时开始计算
+		session.QueriedAt = time.Now().Unix()
+		repositories.ChatSessionRepo.Save(session)
 		message := repositories.MessageRepo.NewNotice(session, "正在为你转接人工客服")
 		repositories.MessageRepo.Save(message)
 		userManager.DeliveryMessage(message, false)
@@ -251,48 +476,169 @@ func (userManager *userManager) addToManual(user contract.User) *models.ChatSess
 
 }
 
-// 触发事件
-func (userManager *userManager) triggerMessageEvent(scene string, message *models.Message) {
+// 回复非营业时间提示，未设置系统规则的回复消息时使用默认提示
+func (userManager *userManager) replyOutOfHours(user contract.User) {
+	// 同一用户一段时间内只提示一次
+	if !chat.BusinessHoursService.ShouldNoticeClosed(user.GetPrimaryKey()) {
+		return
+	}
+	var msg *models.Message
+	rule := repositories.AutoRuleRepo.GetOutOfHours(user.GetGroupId())
+	if rule != nil && rule.ReplyType == models.ReplyTypeMessage {
+		msg = rule.GetReplyMessage(user.GetPrimaryKey())
+		if msg != nil {
+			rule.AddCount()
+		}
+	}
+	if msg == nil {
+		msg = repositories.MessageRepo.NewNotice(&models.ChatSession{
+			UserId:  user.GetPrimaryKey(),
+			GroupId: user.GetGroupId(),
+		}, "当前为非营业时间，您可以留言，客服上班后会尽快回复您")
+	}
+	repositories.MessageRepo.Save(msg)
+	userManager.DeliveryMessage(msg, false)
+}
+
+// ButtonClick 用户点击快捷按钮
+// 按钮的文字作为用户消息保存，有对应客服时投递给客服，否则以按钮的value匹配自动回复规则
+func (userManager *userManager) ButtonClick(conn Conn, msgId int64, value string) error {
+	buttons := repositories.MessageRepo.First([]*repositories.Where{
+		{
+			Filed: "id = ?",
+			Value: msgId,
+		},
+		{
+			Filed: "user_id = ?",
+			Value: conn.GetUserId(),
+		},
+		{
+			Filed: "type = ?",
+			Value: models.TypeButtons,
+		},
+	}, []string{})
+	if buttons == nil {
+		return errors.New("消息不存在")
+	}
+	label, ok := buttons.Payload.GetButtonLabel(value)
+	if !ok {
+		return errors.New("按钮不存在")
+	}
+	msg := &models.Message{
+		UserId:     conn.GetUserId(),
+		AdminId:    chat.UserService.GetValidAdmin(conn.GetUserId()),
+		Type:       models.TypeText,
+		Content:    label,
+		GroupId:    conn.GetGroupId(),
+		Source:     models.SourceUser,
+		SessionId:  buttons.SessionId,
+		ReqId:      random.RandString(20),
+		ReplyTo:    buttons.Id,
+		ReceivedAT: time.Now().Unix(),
+		User:       conn.GetUser().(*models.User),
+		Reply:      buttons,
+	}
+	if msg.AdminId > 0 {
+		session := repositories.ChatSessionRepo.FirstActiveByUser(msg.UserId, msg.AdminId)
+		if session != nil {
+			msg.SessionId = session.Id
+		}
+	}
+	repositories.MessageRepo.Save(msg)
+	conn.Deliver(NewReceiptAction(msg))
+	if msg.AdminId > 0 {
+		_ = chat.AdminService.UpdateUser(msg.AdminId, msg.UserId)
+		AdminManager.DeliveryMessage(msg, false)
+		return nil
+	}
+	if chat.ManualService.IsIn(msg.UserId, msg.GroupId) {
+		AdminManager.BroadcastWaitingUser(msg.GroupId)
+		return nil
+	}
+	if chat.TransferService.GetUserTransferId(msg.UserId) == 0 {
+		rules := repositories.AutoRuleRepo.GetAllActiveNormalByGroup(msg.GroupId)
+		for _, rule := range rules {
+			if rule.IsMatchButton(value) && rule.SceneInclude(models.SceneNotAccepted) {
+				userManager.handleRule(rule, msg)
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// 触发事件，返回是否有匹配的规则
+func (userManager *userManager) triggerMessageEvent(scene string, message *models.Message) bool {
 	rules := repositories.AutoRuleRepo.GetAllActiveNormalByGroup(message.GroupId)
 	for _, rule := range rules {
 		if rule.IsMatch(message.Content) && rule.SceneInclude(scene) {
-			switch rule.ReplyType {
-			// 转接人工客服
-			case models.ReplyTypeTransfer:
-				session := userManager.addToManual(message.GetUser())
+			userManager.handleRule(rule, message)
+			return true
+		}
+	}
+	return false
+}
+
+// 执行匹配到的自动回复规则
+func (userManager *userManager) handleRule(rule *models.AutoRule, message *models.Message) {
+	switch rule.ReplyType {
+	// 转接人工客服
+	case models.ReplyTypeTransfer:
+		session := userManager.addToManual(message.GetUser(), rule.GetQueueId())
+		if session != nil {
+			message.SessionId = session.Id
+			repositories.MessageRepo.Save(message)
+		}
+		AdminManager.BroadcastWaitingUser(message.GroupId)
+		userManager.BroadcastQueueLocation(message.GroupId)
+		AdminManager.BroadcastWaitingUser(message.GetUser().GetGroupId())
+		go AdminManager.AutoAssign(message.GroupId)
+	// 回复消息
+	case models.ReplyTypeMessage:
+		msg := rule.GetReplyMessage(message.UserId)
+		if msg != nil {
+			msg.SessionId = message.SessionId
+			repositories.MessageRepo.Save(msg)
+			userManager.DeliveryMessage(msg, false)
+		}
+	//触发事件
+	case models.ReplyTypeEvent:
+		switch rule.Key {
+		case models.EventBreak:
+			adminId := chat.UserService.GetValidAdmin(message.UserId)
+			if adminId > 0 {
+				session := repositories.ChatSessionRepo.FirstActiveByUser(message.UserId, adminId)
 				if session != nil {
-					message.SessionId = session.Id
-					repositories.MessageRepo.Save(message)
-				}
-				AdminManager.BroadcastWaitingUser(message.GroupId)
-				userManager.BroadcastQueueLocation(message.GroupId)
-				AdminManager.BroadcastWaitingUser(message.GetUser().GetGroupId())
-			// 回复消息
-			case models.ReplyTypeMessage:
-				msg := rule.GetReplyMessage(message.UserId)
-				if msg != nil {
-					msg.SessionId = message.SessionId
-					repositories.MessageRepo.Save(msg)
-					userManager.DeliveryMessage(msg, false)
-				}
-			//触发事件
-			case models.ReplyTypeEvent:
-				switch rule.Key {
-				case "break":
-					adminId := chat.UserService.GetValidAdmin(message.UserId)
-					if adminId > 0 {
-						_ = chat.AdminService.RemoveUser(adminId, message.UserId)
-					}
-					msg := rule.GetReplyMessage(message.UserId)
-					if msg != nil {
-						msg.SessionId = message.SessionId
-						repositories.MessageRepo.Save(msg)
-						userManager.DeliveryMessage(msg, false)
-					}
+					chat.SessionService.Close(session.Id, models.CloseReasonBreak, true, false)
+				} else {
+					_ = chat.AdminService.RemoveUser(adminId, message.UserId)
 				}
 			}
-			rule.AddCount()
-			return
+			msg := rule.GetReplyMessage(message.UserId)
+			if msg != nil {
+				msg.SessionId = message.SessionId
+				repositories.MessageRepo.Save(msg)
+				userManager.DeliveryMessage(msg, false)
+			}
+			if message.SessionId > 0 {
+				userManager.SendSurvey(message.SessionId)
+			}
+		// 创建留言工单，由客服上班后处理
+		case models.EventTicket:
+			repositories.TicketRepo.Save(&models.Ticket{
+				GroupId:   message.GroupId,
+				UserId:    message.UserId,
+				MessageId: message.Id,
+				Content:   message.Content,
+				CreatedAt: time.Now().Unix(),
+			})
+			msg := rule.GetReplyMessage(message.UserId)
+			if msg != nil {
+				msg.SessionId = message.SessionId
+				repositories.MessageRepo.Save(msg)
+				userManager.DeliveryMessage(msg, false)
+			}
 		}
 	}
+	rule.AddCount()
 }
@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"time"
+	"ws/app/chat"
+	"ws/app/models"
+	"ws/app/repositories"
+)
+
+// CheckWaitingUsers 检查待人工接入的用户
+// 排队超过最大等待时长，或断开连接超过设定时长未重连的用户移出队列并取消会话
+func (userManager *userManager) CheckWaitingUsers(gid int64) {
+	timeout := chat.SettingService.GetQueueTimeout(gid)
+	abandonDuration := chat.SettingService.GetAbandonDuration(gid)
+	now := time.Now().Unix()
+	removed := false
+	abandoned := make(map[int64]struct{})
+	for _, uid := range chat.AssignService.GetWaitingUsers(gid) {
+		offlineAt := chat.ManualService.GetOfflineTime(uid, gid)
+		if offlineAt > 0 && offlineAt+abandonDuration < now {
+			if userManager.abandon(gid, uid, models.CancelReasonDisconnect, 0) {
+				removed = true
+			}
+			abandoned[uid] = struct{}{}
+		}
+	}
+	if timeout > 0 {
+		// 只对已超时的会话加锁处理
+		for _, session := range repositories.ChatSessionRepo.GetQueueTimeouts(gid, now-timeout) {
+			if _, ok := abandoned[session.UserId]; ok {
+				continue
+			}
+			if !chat.ManualService.IsIn(session.UserId, gid) {
+				continue
+			}
+			if userManager.abandon(gid, session.UserId, models.CancelReasonTimeout, now-timeout) {
+				removed = true
+			}
+		}
+	}
+	if removed {
+		AdminManager.BroadcastWaitingUser(gid)
+		userManager.BroadcastQueueLocation(gid)
+	}
+}
+
+// 将用户移出队列并取消会话
+// queriedBefore 大于0时只取消在此时间之前加入队列的会话
+func (userManager *userManager) abandon(gid int64, uid int64, reason string, queriedBefore int64) bool {
+	if !chat.AssignService.Lock(uid) {
+		return false
+	}
+	defer chat.AssignService.Unlock(uid)
+	session := repositories.ChatSessionRepo.FirstActiveByUser(uid, 0)
+	if session == nil {
+		// 没有对应的会话，直接移出队列
+		_ = chat.ManualService.Remove(uid, gid)
+		return true
+	}
+	if session.AcceptedAt > 0 || session.CanceledAt > 0 {
+		return false
+	}
+	if queriedBefore > 0 && session.QueriedAt >= queriedBefore {
+		return false
+	}
+	chat.SessionService.Cancel(session, reason)
+	if reason == models.CancelReasonTimeout {
+		userManager.noticeQueueTimeout(session)
+	}
+	return true
+}
+
+// 通知用户排队超时，未设置回复时提示用户留言
+func (userManager *userManager) noticeQueueTimeout(session *models.ChatSession) {
+	var message *models.Message
+	rule := repositories.AutoRuleRepo.GetQueueTimeout(session.GroupId)
+	if rule != nil && rule.ReplyType == models.ReplyTypeMessage {
+		message = rule.GetReplyMessage(session.UserId)
+		if message != nil {
+			message.SessionId = session.Id
+			rule.AddCount()
+		}
+	}
+	if message == nil {
+		message = repositories.MessageRepo.NewNotice(session, "当前客服繁忙，您可以留言，客服上线后会尽快回复您")
+	}
+	repositories.MessageRepo.Save(message)
+	userManager.DeliveryMessage(message, false)
+}
@@ -57,6 +57,7 @@ func (admin *Admin) GetSetting() *AdminChatSetting {
 				CreatedAt:  time.Now(),
 				UpdatedAt:  time.Now(),
 				LastOnline: time.Now(),
+				Status:     AdminStatusOnline,
 			}
 			databases.Db.Save(setting)
 		}
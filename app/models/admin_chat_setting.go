@@ -5,6 +5,14 @@ import (
 	"time"
 )
 
+// 客服状态
+const (
+	AdminStatusOnline    = "online"    // 在线，可接入新用户
+	AdminStatusBusy      = "busy"      // 忙碌，不接入新用户
+	AdminStatusAway      = "away"      // 离开，不接入新用户，用户发送消息时回复离线消息
+	AdminStatusInvisible = "invisible" // 隐身，其他客服看到的是离线
+)
+
 type AdminChatSetting struct {
 	Id             int64     `json:"id"`
 	AdminId        int64     `json:"-" gorm:"uniqueIndex"`
@@ -14,6 +22,9 @@ type AdminChatSetting struct {
 	OfflineContent string    `json:"offline_content" gorm:"size:512"`
 	Name           string    `json:"name" gorm:"size:64"`
 	LastOnline     time.Time `json:"last_online"`
+	Status         string    `json:"status" gorm:"size:16;default:online"`
+	IsAutoAway     bool      `json:"-"`                          // 是否因空闲自动离开
+	MaxChats       int       `json:"max_chats" gorm:"default:0"` // 最大同时接待人数，0使用分组的默认设置
 	Avatar         string    `json:"avatar" gorm:"size:512"`
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
@@ -34,3 +45,22 @@ func (setting *AdminChatSetting) GetOfflineMsg(uid int64, sessionId uint64, grou
 	}
 	return offlineMsg
 }
+
+// GetStatus 客服状态
+func (setting *AdminChatSetting) GetStatus() string {
+	if setting.Status == "" {
+		return AdminStatusOnline
+	}
+	return setting.Status
+}
+
+// IsAcceptable 是否可以接入新用户
+func (setting *AdminChatSetting) IsAcceptable() bool {
+	status := setting.GetStatus()
+	return status == AdminStatusOnline || status == AdminStatusInvisible
+}
+
+// IsVisible 是否对其他客服显示在线
+func (setting *AdminChatSetting) IsVisible() bool {
+	return setting.GetStatus() != AdminStatusInvisible
+}
@@ -1,10 +1,25 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 	"ws/app/resource"
 )
 
+// MaxButtons 快捷按钮的最大数量
+const MaxButtons = 10
+
+// Button 快捷回复按钮，用户点击后以value匹配自动回复规则
+type Button struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// ButtonsContent 快捷按钮消息的内容
+type ButtonsContent struct {
+	Content string    `json:"content"`
+	Buttons []*Button `json:"buttons"`
+}
 
 type AutoMessage struct {
 	ID        uint   `gorm:"column:id;primaryKey"`
@@ -40,7 +55,18 @@ func (message *AutoMessage) TypeLabel() string {
 		return "导航卡片"
 	case TypeImage:
 		return "图片"
+	case TypeButtons:
+		return "快捷按钮"
 	default:
 		return "未知类型"
 	}
 }
+
+// GetButtonsContent 解析快捷按钮消息的内容
+func (message *AutoMessage) GetButtonsContent() *ButtonsContent {
+	content := &ButtonsContent{}
+	if message.Type == TypeButtons {
+		_ = json.Unmarshal([]byte(message.Content), content)
+	}
+	return content
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"github.com/duke-git/lancet/v2/random"
 	"gorm.io/gorm"
+	"strconv"
 	"strings"
 	"time"
 	"ws/app/databases"
@@ -15,6 +16,8 @@ const (
 
 	MatchEnter           = "enter"
 	MatchAdminAllOffLine = "u-offline"
+	MatchQueueTimeout    = "queue-timeout"
+	MatchOutOfHours      = "out-of-hours"
 
 	ReplyTypeMessage  = "message"
 	ReplyTypeTransfer = "transfer"
@@ -23,8 +26,10 @@ const (
 	SceneNotAccepted  = "not-accepted"
 	SceneAdminOnline  = "admin-online"
 	SceneAdminOffline = "admin-offline"
+	SceneClosed       = "closed"
 
-	EventBreak = "break"
+	EventBreak  = "break"
+	EventTicket = "ticket"
 )
 
 var ScenesOptions = []*resource.Options{
@@ -40,12 +45,20 @@ var ScenesOptions = []*resource.Options{
 		Value: SceneAdminOffline,
 		Label: "用户已接入且客服离线",
 	},
+	{
+		Value: SceneClosed,
+		Label: "非营业时间",
+	},
 }
 var EventOptions = []*resource.Options{
 	{
 		Value: EventBreak,
 		Label: "断开当前会话",
 	},
+	{
+		Value: EventTicket,
+		Label: "创建留言工单",
+	},
 }
 
 type AutoRuleScene struct {
@@ -89,6 +102,20 @@ func (rule *AutoRule) IsMatch(str string) bool {
 	return false
 }
 
+// IsMatchButton 快捷按钮点击是否匹配，按钮的value只与完全匹配的规则比较
+func (rule *AutoRule) IsMatchButton(value string) bool {
+	return rule.MatchType == MatchTypeAll && rule.Match == value
+}
+
+// GetQueueId 转人工规则指定的队列，key保存队列id，0为默认队列
+func (rule *AutoRule) GetQueueId() int64 {
+	if rule.ReplyType != ReplyTypeTransfer {
+		return 0
+	}
+	queueId, _ := strconv.ParseInt(rule.Key, 10, 64)
+	return queueId
+}
+
 // SceneInclude 场景
 func (rule *AutoRule) SceneInclude(str string) bool {
 	for _, s := range rule.Scenes {
@@ -174,6 +201,19 @@ func (rule *AutoRule) GetReplyMessage(uid int64) (message *Message) {
 			ReqId:      random.RandString(20),
 			IsRead:     true,
 		}
+		// 快捷按钮消息以提示语作为文本内容，按钮放在payload中
+		if rule.Message.Type == TypeButtons {
+			content := rule.Message.GetButtonsContent()
+			buttons := make([]interface{}, 0, len(content.Buttons))
+			for _, button := range content.Buttons {
+				buttons = append(buttons, map[string]interface{}{
+					"label": button.Label,
+					"value": button.Value,
+				})
+			}
+			message.Content = content.Content
+			message.Payload = Payload{"buttons": buttons}
+		}
 	}
 	return
 }
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+	"ws/app/resource"
+)
+
+// BusinessHour 分组每周的营业时间段，一天可以有多个时间段
+// 分组没有设置任何时间段时视为全天营业
+type BusinessHour struct {
+	Id        int64  `gorm:"primaryKey"`
+	GroupId   int64  `gorm:"index"`
+	Weekday   int    // 0为周日
+	StartTime string `gorm:"size:5"` // 15:04
+	EndTime   string `gorm:"size:5"` // 15:04，24:00为当天结束
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (hour *BusinessHour) ToJson() *resource.BusinessHour {
+	return &resource.BusinessHour{
+		Weekday:   hour.Weekday,
+		StartTime: hour.StartTime,
+		EndTime:   hour.EndTime,
+	}
+}
+
+// Holiday 节假日，当天不使用每周的营业时间
+// IsOpen为true时为调休上班，按设置的时间段营业
+type Holiday struct {
+	Id        int64  `gorm:"primaryKey"`
+	GroupId   int64  `gorm:"index"`
+	Date      string `gorm:"size:10;index"` // 2006-01-02
+	Title     string `gorm:"size:32"`
+	IsOpen    bool
+	StartTime string `gorm:"size:5"`
+	EndTime   string `gorm:"size:5"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (holiday *Holiday) ToJson() *resource.Holiday {
+	return &resource.Holiday{
+		Id:        holiday.Id,
+		Date:      holiday.Date,
+		Title:     holiday.Title,
+		IsOpen:    holiday.IsOpen,
+		StartTime: holiday.StartTime,
+		EndTime:   holiday.EndTime,
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+	"ws/app/resource"
+)
+
+// ChatQueue 人工客服队列，按业务线划分(如账单、技术、售前)
+// 用户进入队列后只有队列的成员客服可以看到和接入，id为0的默认队列所有客服都可以接入
+type ChatQueue struct {
+	Id        int64    `gorm:"primaryKey"`
+	GroupId   int64    `gorm:"index"`
+	Name      string   `gorm:"size:32"`
+	Title     string   `gorm:"size:64"`
+	Admins    []*Admin `gorm:"many2many:chat_queue_admins"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// GetAdminIds 成员客服id
+func (queue *ChatQueue) GetAdminIds() []int64 {
+	ids := make([]int64, 0, len(queue.Admins))
+	for _, admin := range queue.Admins {
+		ids = append(ids, admin.GetPrimaryKey())
+	}
+	return ids
+}
+
+func (queue *ChatQueue) ToJson() *resource.ChatQueue {
+	return &resource.ChatQueue{
+		Id:        queue.Id,
+		Name:      queue.Name,
+		Title:     queue.Title,
+		AdminIds:  queue.GetAdminIds(),
+		CreatedAt: queue.CreatedAt,
+		UpdatedAt: queue.UpdatedAt,
+	}
+}
@@ -8,6 +8,22 @@ import (
 const ChatSessionTypeNormal = 0
 const ChatSessionTypeTransfer = 1
 
+// 排队中取消会话的原因
+const (
+	CancelReasonAdmin      = "admin"      // 客服取消
+	CancelReasonTimeout    = "timeout"    // 排队超时
+	CancelReasonDisconnect = "disconnect" // 用户断开连接超时
+)
+
+// 会话关闭的原因
+const (
+	CloseReasonAdmin    = "admin"    // 客服断开
+	CloseReasonIdle     = "idle"     // 超时未回复
+	CloseReasonOffline  = "offline"  // 客服离线自动断开
+	CloseReasonBreak    = "break"    // 用户触发断开会话规则
+	CloseReasonTransfer = "transfer" // 转接给其他客服
+)
+
 type ChatSession struct {
 	Id         uint64 `gorm:"primaryKey" json:"id"`
 	UserId     int64  `gorm:"index"`
@@ -19,6 +35,15 @@ type ChatSession struct {
 	AdminId    int64  `gorm:"index"`
 	Admin      *Admin `gorm:"foreignKey:admin_id"`
 	Type       int8    `gorm:"default:0"`
+	QueueId    int64   `gorm:"index;default:0"` // 所在的人工队列，0为默认队列
+	CancelReason string `gorm:"size:16"`
+	CloseReason  string `gorm:"size:16;index"`
+	WrapUpId     int64  `gorm:"index;default:0"` // 客服结束会话时选择的小结分类
+	WrapUpRemark string `gorm:"size:255"`
+	WrapUp       *WrapUpCode `gorm:"foreignKey:WrapUpId"`
+	Rating        int8   `gorm:"index;default:0"` // 用户的满意度评价1-5，0为未评价
+	RatingComment string `gorm:"size:512"`
+	RatedAt       int64  `gorm:"default:0"`
 	User       *User  `gorm:"foreignKey:user_id"`
 	Messages []*Message `gorm:"foreignKey:session_id"`
 }
@@ -49,6 +74,34 @@ func (chatSession *ChatSession) getTypeLabel() string {
 		return ""
 	}
 }
+func (chatSession *ChatSession) getCancelReasonLabel() string {
+	switch chatSession.CancelReason {
+	case CancelReasonAdmin:
+		return "客服取消"
+	case CancelReasonTimeout:
+		return "排队超时"
+	case CancelReasonDisconnect:
+		return "用户离开"
+	default:
+		return ""
+	}
+}
+func (chatSession *ChatSession) getCloseReasonLabel() string {
+	switch chatSession.CloseReason {
+	case CloseReasonAdmin:
+		return "客服断开"
+	case CloseReasonIdle:
+		return "超时未回复"
+	case CloseReasonOffline:
+		return "客服离线"
+	case CloseReasonBreak:
+		return "用户断开"
+	case CloseReasonTransfer:
+		return "转接"
+	default:
+		return ""
+	}
+}
 func (chatSession *ChatSession) getStatus() string  {
 	if chatSession.CanceledAt > 0 {
 		return "cancel"
@@ -60,7 +113,7 @@ func (chatSession *ChatSession) getStatus() string  {
 }
 
 func (chatSession *ChatSession) ToJson() *resource.ChatSession {
-	return &resource.ChatSession{
+	json := &resource.ChatSession{
 		Id:         chatSession.Id,
 		UserId:     chatSession.UserId,
 		QueriedAt:  chatSession.QueriedAt * 1000,
@@ -70,8 +123,22 @@ func (chatSession *ChatSession) ToJson() *resource.ChatSession {
 		AdminId:    chatSession.AdminId,
 		TypeLabel:  chatSession.getTypeLabel(),
 		Status: chatSession.getStatus(),
+		QueueId:    chatSession.QueueId,
+		CancelReason: chatSession.CancelReason,
+		CancelReasonLabel: chatSession.getCancelReasonLabel(),
+		CloseReason: chatSession.CloseReason,
+		CloseReasonLabel: chatSession.getCloseReasonLabel(),
+		WrapUpId: chatSession.WrapUpId,
+		WrapUpRemark: chatSession.WrapUpRemark,
+		Rating: chatSession.Rating,
+		RatingComment: chatSession.RatingComment,
+		RatedAt: chatSession.RatedAt * 1000,
 		UserName:   chatSession.GetUser().Username,
 		AdminName:  chatSession.GetAdmin().Username,
 	}
+	if chatSession.WrapUp != nil {
+		json.WrapUpTitle = chatSession.WrapUp.Title
+	}
+	return json
 }
 
@@ -12,6 +12,16 @@ const (
 	MinuteToBreak = "minute-to-break"
 	SystemName = "system-name"
 	SystemAvatar = "system-avatar"
+	MinuteToRecall = "minute-to-recall"
+	IsSingleSession = "is-single-session"
+	MinuteToAway = "minute-to-away"
+	AssignStrategy = "assign-strategy"
+	MaxChats = "max-chats"
+	MinuteToPriority = "minute-to-priority"
+	MinuteToQueueTimeout = "minute-to-queue-timeout"
+	MinuteToAbandon = "minute-to-abandon"
+	Timezone = "timezone"
+	IsSurvey = "is-survey"
 )
 
 type ChatSetting struct {
@@ -11,28 +11,38 @@ const (
 	TypeText     = "text"
 	TypeNavigate = "navigator"
 	TypeNotice   = "notice"
+	TypeButtons  = "buttons"
+	TypeSurvey   = "survey"
 	SourceUser   = 0
 	SourceAdmin  = 1
 	SourceSystem = 2
 )
 
 type Message struct {
-	Id         int64  `gorm:"primaryKey"`
-	UserId     int64  `gorm:"index" mapstructure:"user_id"`
-	AdminId    int64  `gorm:"index"`
-	Type       string `gorm:"size:16" mapstructure:"type"`
-	Content    string `gorm:"size:1024" mapstructure:"content"`
+	Id         int64   `gorm:"primaryKey"`
+	UserId     int64   `gorm:"index" mapstructure:"user_id"`
+	AdminId    int64   `gorm:"index"`
+	Type       string  `gorm:"size:16" mapstructure:"type"`
+	Content    string  `gorm:"size:1024" mapstructure:"content"`
+	Payload    Payload `gorm:"type:json" mapstructure:"payload"`
 	ReceivedAT int64
-	GroupId    int64  `gorm:"group_id"`
-	SendAt     int64  `gorm:"send_at"`
-	Source     int8   `gorm:"source"`
-	SessionId  uint64 `gorm:"session_id"`
-	ReqId      string `gorm:"index" mapstructure:"req_id"`
-	IsRead     bool   `gorm:"bool"`
-	Admin      *Admin `gorm:"foreignKey:admin_id"`
-	User       *User  `gorm:"foreignKey:user_id"`
+	GroupId    int64    `gorm:"group_id"`
+	SendAt     int64    `gorm:"send_at"`
+	Source     int8     `gorm:"source"`
+	SessionId  uint64   `gorm:"session_id"`
+	ReqId      string   `gorm:"index" mapstructure:"req_id"`
+	IsRead     bool     `gorm:"bool"`
+	ReplyTo    int64    `gorm:"index;default:0" mapstructure:"reply_to"`
+	RecalledAt int64    `gorm:"default:0"`
+	EditedAt   int64    `gorm:"default:0"`
+	Admin      *Admin   `gorm:"foreignKey:admin_id"`
+	User       *User    `gorm:"foreignKey:user_id"`
+	Reply      *Message `gorm:"foreignKey:ReplyTo"`
 }
 
+// 引用预览的最大字符数
+const quotePreviewLength = 50
+
 func (message *Message) Save() {
 	databases.Db.Omit(clause.Associations).Save(message)
 }
@@ -79,19 +89,65 @@ func (message *Message) GetAvatar() (avatar string) {
 	}
 	return
 }
+
+// ToQuote 引用预览，内容超出长度截断，撤回的消息不输出内容
+func (message *Message) ToQuote() *resource.QuotedMessage {
+	content := message.Content
+	switch {
+	case message.IsRecalled():
+		content = ""
+	case message.Type == TypeImage:
+		content = "[图片]"
+	default:
+		runes := []rune(content)
+		if len(runes) > quotePreviewLength {
+			content = string(runes[:quotePreviewLength]) + "..."
+		}
+	}
+	return &resource.QuotedMessage{
+		Id:         message.Id,
+		Type:       message.Type,
+		Content:    content,
+		Source:     message.Source,
+		IsRecalled: message.IsRecalled(),
+	}
+}
+
+// IsRecalled 是否已撤回
+func (message *Message) IsRecalled() bool {
+	return message.RecalledAt > 0
+}
+
 func (message *Message) ToJson() *resource.Message {
+	content := message.Content
+	payload := message.Payload
+	// 撤回的消息内容保留在数据库中，不再对外输出
+	if message.IsRecalled() {
+		content = ""
+		payload = nil
+	}
+	// 引用的消息需在查询时预加载Reply
+	var reply *resource.QuotedMessage
+	if message.Reply != nil {
+		reply = message.Reply.ToQuote()
+	}
 	return &resource.Message{
 		Id:         message.Id,
 		UserId:     message.UserId,
 		AdminId:    message.AdminId,
 		AdminName:  message.GetAdminName(),
 		Type:       message.Type,
-		Content:    message.Content,
+		Content:    content,
+		Payload:    payload,
 		ReceivedAT: message.ReceivedAT,
 		Source:     message.Source,
 		ReqId:      message.ReqId,
 		IsSuccess:  true,
 		IsRead:     message.IsRead,
 		Avatar:     message.GetAvatar(),
+		IsRecalled: message.IsRecalled(),
+		EditedAt:   message.EditedAt,
+		ReplyTo:    message.ReplyTo,
+		Reply:      reply,
 	}
 }
@@ -0,0 +1,242 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"unicode/utf8"
+)
+
+const (
+	TypeFile     = "file"
+	TypeAudio    = "audio"
+	TypeVideo    = "video"
+	TypeLocation = "location"
+	TypeCard     = "card"
+)
+
+// 卡片类型
+const (
+	CardProduct = "product"
+	CardOrder   = "order"
+)
+
+const (
+	MaxFileSize      = 20 * 1024 * 1024 // 附件最大字节数
+	MaxAudioDuration = 60               // 语音最大时长(秒)
+	MaxVideoDuration = 5 * 60           // 视频最大时长(秒)
+)
+
+// 使用payload保存结构化内容的消息类型
+var payloadTypes = map[string]string{
+	TypeFile:     "[文件]",
+	TypeAudio:    "[语音]",
+	TypeVideo:    "[视频]",
+	TypeLocation: "[位置]",
+	TypeCard:     "[卡片]",
+}
+
+// IsPayloadType 是否使用payload保存内容的消息类型
+func IsPayloadType(t string) bool {
+	_, ok := payloadTypes[t]
+	return ok
+}
+
+// Payload 消息的结构化内容
+// file: url,name,size,mime
+// audio: url,duration
+// video: url,duration,cover
+// location: latitude,longitude,name,address
+// card: card_type,title,description,image,price,url,order_no
+type Payload map[string]interface{}
+
+func (payload Payload) Value() (driver.Value, error) {
+	if len(payload) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(payload)
+	return string(b), err
+}
+
+func (payload *Payload) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*payload = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported payload type %T", value)
+	}
+	return json.Unmarshal(b, payload)
+}
+
+// GetString 获取字符串字段
+func (payload Payload) GetString(key string) string {
+	s, _ := payload[key].(string)
+	return s
+}
+
+// GetNumber 获取数字字段，json解码为float64，msgpack解码为int64/uint64
+func (payload Payload) GetNumber(key string) (float64, bool) {
+	switch n := payload[key].(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// Validate 根据消息类型验证payload
+func (payload Payload) Validate(t string) error {
+	switch t {
+	case TypeFile:
+		if err := payload.validateUrl("url", 1); err != nil {
+			return err
+		}
+		if err := payload.validateString("name", 1, 255); err != nil {
+			return err
+		}
+		if err := payload.validateNumber("size", 1, MaxFileSize); err != nil {
+			return err
+		}
+		return payload.validateString("mime", 0, 128)
+	case TypeAudio:
+		if err := payload.validateUrl("url", 1); err != nil {
+			return err
+		}
+		return payload.validateNumber("duration", 1, MaxAudioDuration)
+	case TypeVideo:
+		if err := payload.validateUrl("url", 1); err != nil {
+			return err
+		}
+		if err := payload.validateNumber("duration", 1, MaxVideoDuration); err != nil {
+			return err
+		}
+		return payload.validateUrl("cover", 0)
+	case TypeLocation:
+		if err := payload.validateNumber("latitude", -90, 90); err != nil {
+			return err
+		}
+		if err := payload.validateNumber("longitude", -180, 180); err != nil {
+			return err
+		}
+		if err := payload.validateString("name", 0, 64); err != nil {
+			return err
+		}
+		return payload.validateString("address", 0, 255)
+	case TypeCard:
+		cardType := payload.GetString("card_type")
+		if cardType != CardProduct && cardType != CardOrder {
+			return errors.New("卡片类型不合法")
+		}
+		if err := payload.validateString("title", 1, 64); err != nil {
+			return err
+		}
+		if err := payload.validateString("description", 0, 255); err != nil {
+			return err
+		}
+		if err := payload.validateUrl("image", 0); err != nil {
+			return err
+		}
+		if err := payload.validateString("price", 0, 32); err != nil {
+			return err
+		}
+		if err := payload.validateUrl("url", 0); err != nil {
+			return err
+		}
+		if cardType == CardOrder {
+			return payload.validateString("order_no", 1, 64)
+		}
+		return nil
+	}
+	return errors.New("消息不合法")
+}
+
+// 链接字段验证，只允许http(s)链接，min为0时字段可以不存在或为空
+func (payload Payload) validateUrl(key string, min int) error {
+	if err := payload.validateString(key, min, 512); err != nil {
+		return err
+	}
+	s := payload.GetString(key)
+	if s == "" {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s不合法", key)
+	}
+	return nil
+}
+
+// 字符串字段验证，min为0时字段可以不存在
+func (payload Payload) validateString(key string, min int, max int) error {
+	v, exist := payload[key]
+	if !exist {
+		if min > 0 {
+			return fmt.Errorf("%s不能为空", key)
+		}
+		return nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("%s不合法", key)
+	}
+	length := utf8.RuneCountInString(s)
+	if length < min || length > max {
+		return fmt.Errorf("%s长度不合法", key)
+	}
+	return nil
+}
+
+// 数字字段验证
+func (payload Payload) validateNumber(key string, min float64, max float64) error {
+	n, ok := payload.GetNumber(key)
+	if !ok {
+		return fmt.Errorf("%s不合法", key)
+	}
+	if n < min || n > max {
+		return fmt.Errorf("%s超出范围", key)
+	}
+	return nil
+}
+
+// GetButtonLabel 快捷按钮消息中value对应按钮的文字
+func (payload Payload) GetButtonLabel(value string) (string, bool) {
+	buttons, _ := payload["buttons"].([]interface{})
+	for _, item := range buttons {
+		button, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, _ := button["value"].(string); v == value {
+			label, _ := button["label"].(string)
+			return label, true
+		}
+	}
+	return "", false
+}
+
+// GetSummary payload消息的文本摘要，用于列表预览和消息推送
+func (payload Payload) GetSummary(t string) string {
+	prefix := payloadTypes[t]
+	switch t {
+	case TypeFile:
+		return prefix + payload.GetString("name")
+	case TypeLocation:
+		return prefix + payload.GetString("name")
+	case TypeCard:
+		return prefix + payload.GetString("title")
+	}
+	return prefix
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"ws/app/resource"
+)
+
+// MessageRevision 消息编辑记录，Content为编辑前的内容
+type MessageRevision struct {
+	Id        int64  `gorm:"primaryKey"`
+	MessageId int64  `gorm:"index"`
+	AdminId   int64  `gorm:"index"`
+	Content   string `gorm:"size:1024"`
+	CreatedAt int64
+}
+
+func (revision *MessageRevision) ToJson() *resource.MessageRevision {
+	return &resource.MessageRevision{
+		Id:        revision.Id,
+		MessageId: revision.MessageId,
+		Content:   revision.Content,
+		CreatedAt: revision.CreatedAt,
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"ws/app/resource"
+)
+
+// Note 客服的内部备注，用户不可见
+// SessionId为0时为用户的备注，否则为会话的备注
+type Note struct {
+	Id        int64
+	GroupId   int64  `gorm:"index"`
+	UserId    int64  `gorm:"index"`
+	SessionId uint64 `gorm:"index;default:0"`
+	AdminId   int64  `gorm:"index"` // 作者
+	Content   string `gorm:"size:1024"`
+	CreatedAt int64
+	UpdatedAt int64
+	Admin     *Admin `gorm:"foreignKey:admin_id"`
+}
+
+func (note *Note) ToJson() *resource.Note {
+	json := &resource.Note{
+		Id:        note.Id,
+		UserId:    note.UserId,
+		SessionId: note.SessionId,
+		AdminId:   note.AdminId,
+		Content:   note.Content,
+		CreatedAt: note.CreatedAt * 1000,
+		UpdatedAt: note.UpdatedAt * 1000,
+	}
+	if note.Admin != nil {
+		json.AdminName = note.Admin.GetUsername()
+	}
+	return json
+}
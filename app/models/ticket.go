@@ -0,0 +1,37 @@
+package models
+
+import (
+	"ws/app/resource"
+)
+
+// Ticket 留言工单，非营业时间用户的留言，由客服上班后处理
+type Ticket struct {
+	Id        int64
+	GroupId   int64  `gorm:"index"`
+	UserId    int64  `gorm:"index"`
+	MessageId int64  `gorm:"index"`
+	Content   string `gorm:"size:512"`
+	AdminId   int64  `gorm:"index"` // 处理的客服
+	CreatedAt int64
+	HandledAt int64
+	User      *User  `gorm:"foreignKey:user_id"`
+	Admin     *Admin `gorm:"foreignKey:admin_id"`
+}
+
+func (ticket *Ticket) ToJson() *resource.Ticket {
+	json := &resource.Ticket{
+		Id:        ticket.Id,
+		UserId:    ticket.UserId,
+		MessageId: ticket.MessageId,
+		Content:   ticket.Content,
+		CreatedAt: ticket.CreatedAt * 1000,
+		HandledAt: ticket.HandledAt * 1000,
+	}
+	if ticket.User != nil {
+		json.Username = ticket.User.GetUsername()
+	}
+	if ticket.Admin != nil {
+		json.AdminName = ticket.Admin.GetUsername()
+	}
+	return json
+}
@@ -10,6 +10,13 @@ import (
 	"ws/app/databases"
 )
 
+// 用户优先级，转人工时优先级高的用户排在前面
+const (
+	UserPriorityNormal int8 = 0
+	UserPriorityVip    int8 = 1
+	UserPrioritySvip   int8 = 2
+)
+
 type User struct {
 	ID        int64
 	CreatedAt *time.Time
@@ -19,6 +26,7 @@ type User struct {
 	ApiToken  string `gogm:"string;size:255"  json:"-"`
 	OpenId    string `gorm:"string;size:255"`
 	GroupId   int64  `gorm:"group_id"`
+	Priority  int8   `gorm:"default:0" json:"priority"`
 }
 
 func (user *User) AccessTo(admin contract.User) bool {
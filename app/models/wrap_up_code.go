@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+	"ws/app/resource"
+)
+
+// WrapUpCode 会话小结分类(如退款、问题反馈)，客服结束会话时选择，用于统计会话的处理结果
+type WrapUpCode struct {
+	Id        int64  `gorm:"primaryKey"`
+	GroupId   int64  `gorm:"index"`
+	Name      string `gorm:"size:32"`
+	Title     string `gorm:"size:64"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (code *WrapUpCode) ToJson() *resource.WrapUpCode {
+	return &resource.WrapUpCode{
+		Id:        code.Id,
+		Name:      code.Name,
+		Title:     code.Title,
+		CreatedAt: code.CreatedAt,
+		UpdatedAt: code.UpdatedAt,
+	}
+}
@@ -17,6 +17,10 @@ func (repo *adminRepo) UpdateSetting(setting *models.AdminChatSetting, column st
 	databases.Db.Model(setting).Update(column, value)
 }
 
+func (repo *adminRepo) UpdateSettings(setting *models.AdminChatSetting, values map[string]interface{}) {
+	databases.Db.Model(setting).Updates(values)
+}
+
 func (repo *adminRepo) Save(admin *models.Admin) {
 	databases.Db.Omit(clause.Associations).Save(admin)
 }
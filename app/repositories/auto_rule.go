@@ -66,6 +66,42 @@ func (repo *autoRuleRepo) GetEnterByGroup(gid int64) *models.AutoRule {
 	}, []string{})
 }
 
+// GetOutOfHours 获取非营业时间转接人工规则
+func (repo *autoRuleRepo) GetOutOfHours(gid int64) *models.AutoRule {
+	return repo.Repository.First([]*Where{
+		{
+			Filed: "is_system = ?",
+			Value: 1,
+		},
+		{
+			Filed: "`match` = ?",
+			Value: models.MatchOutOfHours,
+		},
+		{
+			Filed: "group_id = ?",
+			Value: gid,
+		},
+	}, []string{})
+}
+
+// GetQueueTimeout 获取排队超时规则
+func (repo *autoRuleRepo) GetQueueTimeout(gid int64) *models.AutoRule {
+	return repo.Repository.First([]*Where{
+		{
+			Filed: "is_system = ?",
+			Value: 1,
+		},
+		{
+			Filed: "`match` = ?",
+			Value: models.MatchQueueTimeout,
+		},
+		{
+			Filed: "group_id = ?",
+			Value: gid,
+		},
+	}, []string{})
+}
+
 // GetAdminAllOffLine 获取转接人工时没有客服在线规则
 func (repo *autoRuleRepo) GetAdminAllOffLine(gid int64) *models.AutoRule {
 	return repo.Repository.First([]*Where{
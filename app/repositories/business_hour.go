@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"ws/app/databases"
+	"ws/app/models"
+
+	"gorm.io/gorm"
+)
+
+type businessHourRepo struct {
+	Repository[models.BusinessHour]
+}
+
+// GetByGroup 获取分组每周的营业时间段
+func (repo *businessHourRepo) GetByGroup(gid int64) []*models.BusinessHour {
+	return repo.Get([]*Where{
+		{
+			Filed: "group_id = ?",
+			Value: gid,
+		},
+	}, -1, []string{}, []string{"weekday", "start_time"})
+}
+
+// Replace 替换分组每周的营业时间段
+func (repo *businessHourRepo) Replace(gid int64, hours []*models.BusinessHour) error {
+	return databases.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", gid).Delete(&models.BusinessHour{}).Error; err != nil {
+			return err
+		}
+		if len(hours) == 0 {
+			return nil
+		}
+		return tx.Create(&hours).Error
+	})
+}
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"ws/app/databases"
+	"ws/app/models"
+)
+
+type chatQueueRepo struct {
+	Repository[models.ChatQueue]
+}
+
+// FirstByName 通过名称获取分组的队列
+func (repo *chatQueueRepo) FirstByName(gid int64, name string) *models.ChatQueue {
+	return repo.First([]*Where{
+		{
+			Filed: "group_id = ?",
+			Value: gid,
+		},
+		{
+			Filed: "name = ?",
+			Value: name,
+		},
+	}, []string{})
+}
+
+// FirstByGroup 获取分组下指定id的队列
+func (repo *chatQueueRepo) FirstByGroup(gid int64, id interface{}) *models.ChatQueue {
+	return repo.First([]*Where{
+		{
+			Filed: "group_id = ?",
+			Value: gid,
+		},
+		{
+			Filed: "id = ?",
+			Value: id,
+		},
+	}, []string{})
+}
+
+// GetIdsByGroupAdmins 获取分组中每个客服所属的队列id，客服id => 队列id
+func (repo *chatQueueRepo) GetIdsByGroupAdmins(gid int64) map[int64][]int64 {
+	rows := make([]struct {
+		AdminId     int64
+		ChatQueueId int64
+	}, 0)
+	databases.Db.Table("chat_queue_admins").
+		Select("chat_queue_admins.admin_id, chat_queue_admins.chat_queue_id").
+		Joins("join chat_queues on chat_queues.id = chat_queue_admins.chat_queue_id").
+		Where("chat_queues.group_id = ?", gid).
+		Scan(&rows)
+	ids := make(map[int64][]int64)
+	for _, row := range rows {
+		ids[row.AdminId] = append(ids[row.AdminId], row.ChatQueueId)
+	}
+	return ids
+}
+
+// GetAdminIds 获取队列的成员客服id
+func (repo *chatQueueRepo) GetAdminIds(queueId int64) []int64 {
+	ids := make([]int64, 0)
+	databases.Db.Table("chat_queue_admins").
+		Where("chat_queue_id = ?", queueId).
+		Pluck("admin_id", &ids)
+	return ids
+}
+
+// IsMember 客服是否可以接入队列中的用户，默认队列所有客服都可以接入
+func (repo *chatQueueRepo) IsMember(queueId int64, adminId int64) bool {
+	if queueId == 0 {
+		return true
+	}
+	var count int64
+	databases.Db.Table("chat_queue_admins").
+		Where("chat_queue_id = ?", queueId).
+		Where("admin_id = ?", adminId).
+		Count(&count)
+	return count > 0
+}
+
+// ReplaceAdmins 更新队列的成员客服
+func (repo *chatQueueRepo) ReplaceAdmins(queue *models.ChatQueue, admins []*models.Admin) error {
+	queue.Admins = admins
+	return databases.Db.Model(queue).Association("Admins").Replace(admins)
+}
@@ -3,12 +3,19 @@ package repositories
 import "ws/app/models"
 
 var (
-	AdminRepo       = &adminRepo{}
-	AutoMessageRepo = &autoMessageRepo{}
-	AutoRuleRepo    = &autoRuleRepo{}
-	ChatSettingRepo = &Repository[models.ChatSetting]{}
-	MessageRepo     = &messageRepo{}
-	ChatSessionRepo = &chatSessionRepo{}
-	TransferRepo    = &transferRepo{}
-	UserRepo        = &userRepo{}
+	AdminRepo        = &adminRepo{}
+	AutoMessageRepo  = &autoMessageRepo{}
+	AutoRuleRepo     = &autoRuleRepo{}
+	BusinessHourRepo = &businessHourRepo{}
+	HolidayRepo      = &Repository[models.Holiday]{}
+	TicketRepo       = &Repository[models.Ticket]{}
+	ChatSettingRepo  = &Repository[models.ChatSetting]{}
+	ChatQueueRepo    = &chatQueueRepo{}
+	MessageRepo      = &messageRepo{}
+	NoteRepo         = &noteRepo{}
+	RevisionRepo     = &Repository[models.MessageRevision]{}
+	ChatSessionRepo  = &chatSessionRepo{}
+	TransferRepo     = &transferRepo{}
+	UserRepo         = &userRepo{}
+	WrapUpCodeRepo   = &wrapUpCodeRepo{}
 )
@@ -21,6 +21,20 @@ func (repo *messageRepo) GetUnSend(wheres []*Where) []*models.Message {
 	return repo.Get(wheres, -1, []string{}, []string{"id desc"})
 }
 
+// FirstWithReply 通过id获取消息，并加载引用的消息
+func (repo *messageRepo) FirstWithReply(id int64) *models.Message {
+	messages := repo.Get([]*Where{
+		{
+			Filed: "id = ?",
+			Value: id,
+		},
+	}, 1, []string{"Reply"}, []string{})
+	if len(messages) == 0 {
+		return nil
+	}
+	return messages[0]
+}
+
 func (repo *messageRepo) NewNotice(session *models.ChatSession, content string) *models.Message {
 	return &models.Message{
 		UserId:     session.UserId,
@@ -34,3 +48,63 @@ func (repo *messageRepo) NewNotice(session *models.ChatSession, content string)
 		ReqId:      random.RandString(20),
 	}
 }
+
+// ReadByAdmin admin已读与user之间id(含)之前的消息，msgId为0时表示全部
+// 返回实际已读到的消息id，没有消息被标记时返回0
+func (repo *messageRepo) ReadByAdmin(adminId int64, uid int64, msgId int64) int64 {
+	wheres := []*Where{
+		{
+			Filed: "admin_id = ?",
+			Value: adminId,
+		},
+		{
+			Filed: "user_id = ?",
+			Value: uid,
+		},
+	}
+	if msgId == 0 {
+		last := repo.First(wheres, []string{"id desc"})
+		if last == nil {
+			return 0
+		}
+		msgId = last.Id
+	}
+	wheres = append(wheres, &Where{
+		Filed: "is_read = ?",
+		Value: 0,
+	}, &Where{
+		Filed: "id <= ?",
+		Value: msgId,
+	})
+	if repo.Update(wheres, map[string]interface{}{
+		"is_read": 1,
+	}) == 0 {
+		return 0
+	}
+	return msgId
+}
+
+// ReadByUser user已读id(含)之前的消息
+// 返回实际已读到的消息id，没有消息被标记时返回0
+func (repo *messageRepo) ReadByUser(uid int64, msgId int64) int64 {
+	wheres := []*Where{
+		{
+			Filed: "id <= ?",
+			Value: msgId,
+		},
+		{
+			Filed: "user_id = ?",
+			Value: uid,
+		},
+		{
+			Filed: "is_read = ?",
+			Value: 0,
+		},
+	}
+	if repo.Update(wheres, map[string]interface{}{
+		"is_read": 1,
+	}) == 0 {
+		return 0
+	}
+	return msgId
+}
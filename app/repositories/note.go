@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"ws/app/models"
+)
+
+type noteRepo struct {
+	Repository[models.Note]
+}
+
+// GetByUser 获取用户的所有备注，包含会话的备注
+func (repo *noteRepo) GetByUser(uid int64) []*models.Note {
+	return repo.Get([]*Where{
+		{
+			Filed: "user_id = ?",
+			Value: uid,
+		},
+	}, -1, []string{"Admin"}, []string{"id desc"})
+}
+
+// GetByUsers 获取多个用户的所有备注
+func (repo *noteRepo) GetByUsers(uids []int64) []*models.Note {
+	return repo.Get([]*Where{
+		{
+			Filed: "user_id in ?",
+			Value: uids,
+		},
+	}, -1, []string{"Admin"}, []string{"id desc"})
+}
+
+// GetBySession 获取会话的备注和会话用户的备注
+func (repo *noteRepo) GetBySession(session *models.ChatSession) []*models.Note {
+	return repo.Get([]*Where{
+		{
+			Filed: "user_id = ?",
+			Value: session.UserId,
+		},
+		{
+			Filed: "session_id in ?",
+			Value: []uint64{0, session.Id},
+		},
+	}, -1, []string{"Admin"}, []string{"id desc"})
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strconv"
 	"ws/app/databases"
-	"ws/app/models"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -83,7 +82,7 @@ func (Repository *Repository[T]) Paginate(c *gin.Context, wheres []*Where, load
 		Scopes(AddOrder(order)).
 		Find(&items)
 	var total int64
-	databases.Db.Model(&models.Admin{}).
+	databases.Db.Model(new(T)).
 		Scopes(AddWhere(wheres)).
 		Count(&total)
 	return NewPagination(items, total)
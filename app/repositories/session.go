@@ -39,12 +39,42 @@ func (session *chatSessionRepo) GetWaitHandles() []*models.ChatSession {
 				Value: models.ChatSessionTypeNormal,
 			},
 		})).
-		Preload("Messages", "source = ?", models.SourceUser).
+		Preload("Messages", "source = ? and recalled_at = ?", models.SourceUser, 0).
 		Preload("User").
 		Find(&sessions)
 	return sessions
 }
 
+// GetQueueTimeouts 获取分组中在指定时间之前加入队列且仍未被接入的会话
+func (session *chatSessionRepo) GetQueueTimeouts(gid int64, queriedBefore int64) []*models.ChatSession {
+	return session.Get([]*Where{
+		{
+			Filed: "group_id = ?",
+			Value: gid,
+		},
+		{
+			Filed: "admin_id = ?",
+			Value: 0,
+		},
+		{
+			Filed: "accepted_at = ?",
+			Value: 0,
+		},
+		{
+			Filed: "canceled_at = ?",
+			Value: 0,
+		},
+		{
+			Filed: "broke_at = ?",
+			Value: 0,
+		},
+		{
+			Filed: "queried_at < ?",
+			Value: queriedBefore,
+		},
+	}, -1, []string{}, []string{})
+}
+
 // FirstActiveByUser 获取有效会话
 func (session *chatSessionRepo) FirstActiveByUser(uid int64, adminId int64) *models.ChatSession {
 	s := session.Repository.First([]*Where{
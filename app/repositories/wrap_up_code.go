@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"ws/app/models"
+)
+
+type wrapUpCodeRepo struct {
+	Repository[models.WrapUpCode]
+}
+
+// FirstByName 通过名称获取分组的小结分类
+func (repo *wrapUpCodeRepo) FirstByName(gid int64, name string) *models.WrapUpCode {
+	return repo.First([]*Where{
+		{
+			Filed: "group_id = ?",
+			Value: gid,
+		},
+		{
+			Filed: "name = ?",
+			Value: name,
+		},
+	}, []string{})
+}
+
+// FirstByGroup 获取分组的小结分类
+func (repo *wrapUpCodeRepo) FirstByGroup(gid int64, id interface{}) *models.WrapUpCode {
+	return repo.First([]*Where{
+		{
+			Filed: "id = ?",
+			Value: id,
+		},
+		{
+			Filed: "group_id = ?",
+			Value: gid,
+		},
+	}, []string{})
+}
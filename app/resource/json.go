@@ -17,8 +17,10 @@ type Admin struct {
 	Avatar        string `json:"avatar"`
 	Username      string `json:"username"`
 	Online        bool   `json:"online"`
+	Status        string `json:"status"`
 	Id            int64  `json:"id"`
 	AcceptedCount int    `json:"accepted_count"`
+	MaxChats      int    `json:"max_chats"` // 最大同时接待人数，0为不限制
 }
 
 type AutoMessage struct {
@@ -62,6 +64,18 @@ type ChatSession struct {
 	AdminName  string `json:"admin_name"`
 	TypeLabel  string `json:"type_label"`
 	Status     string `json:"status"`
+	QueueId    int64  `json:"queue_id"`
+
+	CancelReason      string `json:"cancel_reason"`
+	CancelReasonLabel string `json:"cancel_reason_label"`
+	CloseReason       string `json:"close_reason"`
+	CloseReasonLabel  string `json:"close_reason_label"`
+	WrapUpId          int64  `json:"wrap_up_id"`
+	WrapUpTitle       string `json:"wrap_up_title"`
+	WrapUpRemark      string `json:"wrap_up_remark"`
+	Rating            int8   `json:"rating"`
+	RatingComment     string `json:"rating_comment"`
+	RatedAt           int64  `json:"rated_at"`
 }
 
 type SimpleMessage struct {
@@ -71,18 +85,48 @@ type SimpleMessage struct {
 }
 
 type Message struct {
+	Id         int64                  `json:"id"`
+	UserId     int64                  `json:"user_id"`
+	AdminId    int64                  `json:"admin_id"`
+	AdminName  string                 `json:"admin_name"`
+	Type       string                 `json:"type"`
+	Content    string                 `json:"content"`
+	Payload    map[string]interface{} `json:"payload,omitempty"`
+	ReceivedAT int64                  `json:"received_at"`
+	Source     int8                   `json:"source"`
+	ReqId      string                 `json:"req_id"`
+	IsSuccess  bool                   `json:"is_success"`
+	IsRead     bool                   `json:"is_read"`
+	Avatar     string                 `json:"avatar"`
+	IsRecalled bool                   `json:"is_recalled"`
+	EditedAt   int64                  `json:"edited_at"`
+	ReplyTo    int64                  `json:"reply_to"`
+	Reply      *QuotedMessage         `json:"reply,omitempty"`
+}
+
+// QuotedMessage 引用消息的预览
+type QuotedMessage struct {
 	Id         int64  `json:"id"`
-	UserId     int64  `json:"user_id"`
-	AdminId    int64  `json:"admin_id"`
-	AdminName  string `json:"admin_name"`
 	Type       string `json:"type"`
 	Content    string `json:"content"`
-	ReceivedAT int64  `json:"received_at"`
 	Source     int8   `json:"source"`
-	ReqId      string `json:"req_id"`
-	IsSuccess  bool   `json:"is_success"`
-	IsRead     bool   `json:"is_read"`
-	Avatar     string `json:"avatar"`
+	IsRecalled bool   `json:"is_recalled"`
+}
+
+type MessageRevision struct {
+	Id        int64  `json:"id"`
+	MessageId int64  `json:"message_id"`
+	Content   string `json:"content"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type ChatQueue struct {
+	Id        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Title     string    `json:"title"`
+	AdminIds  []int64   `json:"admin_ids"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type WaitingChatSession struct {
@@ -94,19 +138,74 @@ type WaitingChatSession struct {
 	MessageCount int              `json:"message_count"`
 	Description  string           `json:"description"`
 	SessionId    uint64           `json:"session_id"`
+	QueueId      int64            `json:"queue_id"`
+	Priority     int8             `json:"priority"`
+}
+
+// QueueLocation 排队位置和预计等待时长
+type QueueLocation struct {
+	Count       int64 `json:"count"`        // 前面等待的人数
+	WaitTime    int64 `json:"wait_time"`    // 预计等待秒数，-1为无法预计
+	WaitMinutes int64 `json:"wait_minutes"` // 预计等待分钟数，向上取整，-1为无法预计
+}
+
+type WrapUpCode struct {
+	Id        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Note struct {
+	Id        int64  `json:"id"`
+	UserId    int64  `json:"user_id"`
+	SessionId uint64 `json:"session_id"`
+	AdminId   int64  `json:"admin_id"`
+	AdminName string `json:"admin_name"`
+	Content   string `json:"content"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
 }
 
 type ChatTransfer struct {
-	Id            int64  `json:"id"`
-	SessionId     uint64 `json:"session_id"`
-	UserId        int64  `json:"user_id"`
-	Remark        string `json:"remark"`
-	FromAdminName string `json:"from_admin_name"`
-	ToAdminName   string `json:"to_admin_name"`
-	Username      string `json:"username"`
-	CreatedAt     int64  `json:"created_at"`
-	AcceptedAt    int64  `json:"accepted_at"`
-	CanceledAt    int64  `json:"canceled_at"`
+	Id            int64   `json:"id"`
+	SessionId     uint64  `json:"session_id"`
+	UserId        int64   `json:"user_id"`
+	Remark        string  `json:"remark"`
+	FromAdminName string  `json:"from_admin_name"`
+	ToAdminName   string  `json:"to_admin_name"`
+	Username      string  `json:"username"`
+	CreatedAt     int64   `json:"created_at"`
+	AcceptedAt    int64   `json:"accepted_at"`
+	CanceledAt    int64   `json:"canceled_at"`
+	Notes         []*Note `json:"notes,omitempty"` // 用户的内部备注，转接给客服时附带
+}
+
+type BusinessHour struct {
+	Weekday   int    `json:"weekday"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+type Holiday struct {
+	Id        int64  `json:"id"`
+	Date      string `json:"date"`
+	Title     string `json:"title"`
+	IsOpen    bool   `json:"is_open"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+type Ticket struct {
+	Id        int64  `json:"id"`
+	UserId    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	MessageId int64  `json:"message_id"`
+	Content   string `json:"content"`
+	AdminName string `json:"admin_name"`
+	CreatedAt int64  `json:"created_at"`
+	HandledAt int64  `json:"handled_at"`
 }
 
 type ChatSetting struct {
@@ -34,6 +34,15 @@ func NoticeUserTransfer(id int64, server string) {
 	_ = c.Call(context.Background(), "UserTransfer", req, resp)
 }
 
+func NoticeUserAssigned(adminId int64, uid int64, unread int, server string) {
+	d, _ := client.NewPeer2PeerDiscovery(server, "")
+	c := client.NewXClient("Admin", client.Failtry, client.RandomSelect, d, client.DefaultOption)
+	defer c.Close()
+	req := &request.AssignRequest{AdminId: adminId, UserId: uid, Unread: unread}
+	resp := &response.NilResponse{}
+	_ = c.Call(context.Background(), "UserAssigned", req, resp)
+}
+
 func NoticeUserOnline(uid int64, server string) {
 	d, _ := client.NewPeer2PeerDiscovery(server, "")
 	c := client.NewXClient("Admin", client.Failtry, client.RandomSelect, d, client.DefaultOption)
@@ -52,6 +61,15 @@ func NoticeUserOffLine(uid int64, server string) {
 	_ = c.Call(context.Background(), "UserOffline", req, resp)
 }
 
+func BroadcastNote(noteId int64) {
+	d := NewDiscovery("Admin")
+	c := client.NewXClient("Admin", client.Failtry, client.RandomSelect, d, client.DefaultOption)
+	defer c.Close()
+	req := &request.IdRequest{Id: noteId}
+	resp := &response.NilResponse{}
+	_ = c.Broadcast(context.Background(), "Note", req, resp)
+}
+
 func BroadcastWaitingUser(groupId int64) {
 	d := NewDiscovery("Admin")
 	c := client.NewXClient("Admin", client.Failtry, client.RandomSelect, d, client.DefaultOption)
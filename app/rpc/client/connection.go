@@ -18,6 +18,16 @@ func NoticeRepeatConnect(id int64, types string, newUuid string, server string)
 	_ = c.Call(context.Background(), "RepeatConnect", req, resp)
 }
 
+// SendSseMessage 转发sse连接POST提交的消息到连接所在server
+func SendSseMessage(id int64, types string, uuid string, body []byte, server string) error {
+	d, _ := client.NewPeer2PeerDiscovery(server, "")
+	c := client.NewXClient("Connection", client.Failtry, client.RandomSelect, d, client.DefaultOption)
+	defer c.Close()
+	req := &request.SseMessageRequest{Types: types, Id: id, Uuid: uuid, Body: body}
+	resp := &response.NilResponse{}
+	return c.Call(context.Background(), "SseMessage", req, resp)
+}
+
 func ConnectionOnline(id int64, types string, server string) bool {
 	d, _ := client.NewPeer2PeerDiscovery(server, "")
 	c := client.NewXClient("Connection", client.Failtry, client.RandomSelect, d, client.DefaultOption)
@@ -15,3 +15,39 @@ func SendMessage(id int64, server string) {
 	resp := &response.NilResponse{}
 	c.Call(context.Background(), "Send", req, resp)
 }
+
+func SendTyping(types string, action string, uid int64, adminId int64, server string) {
+	d, _ := client.NewPeer2PeerDiscovery(server, "")
+	c := client.NewXClient("Message", client.Failtry, client.RandomSelect, d, client.DefaultOption)
+	defer c.Close()
+	req := &request.TypingRequest{Types: types, Action: action, UserId: uid, AdminId: adminId}
+	resp := &response.NilResponse{}
+	_ = c.Call(context.Background(), "Typing", req, resp)
+}
+
+func SendRead(types string, uid int64, adminId int64, msgId int64, server string) {
+	d, _ := client.NewPeer2PeerDiscovery(server, "")
+	c := client.NewXClient("Message", client.Failtry, client.RandomSelect, d, client.DefaultOption)
+	defer c.Close()
+	req := &request.ReadRequest{Types: types, UserId: uid, AdminId: adminId, MsgId: msgId}
+	resp := &response.NilResponse{}
+	_ = c.Call(context.Background(), "Read", req, resp)
+}
+
+func SendRecall(id int64, server string) {
+	d, _ := client.NewPeer2PeerDiscovery(server, "")
+	c := client.NewXClient("Message", client.Failtry, client.RandomSelect, d, client.DefaultOption)
+	defer c.Close()
+	req := &request.SendMessageRequest{Id: id}
+	resp := &response.NilResponse{}
+	_ = c.Call(context.Background(), "Recall", req, resp)
+}
+
+func SendEdit(id int64, server string) {
+	d, _ := client.NewPeer2PeerDiscovery(server, "")
+	c := client.NewXClient("Message", client.Failtry, client.RandomSelect, d, client.DefaultOption)
+	defer c.Close()
+	req := &request.SendMessageRequest{Id: id}
+	resp := &response.NilResponse{}
+	_ = c.Call(context.Background(), "Edit", req, resp)
+}
@@ -27,6 +27,12 @@ type IdsRequest struct {
 	GroupId int64
 }
 
+type AssignRequest struct {
+	AdminId int64
+	UserId  int64
+	Unread  int
+}
+
 type SendMessageRequest struct {
 	Id int64
 }
@@ -36,3 +42,24 @@ type RepeatConnectRequest struct {
 	Id      int64
 	NewUuid string
 }
+
+type SseMessageRequest struct {
+	Types string
+	Id    int64
+	Uuid  string
+	Body  []byte
+}
+
+type TypingRequest struct {
+	Types   string
+	Action  string
+	UserId  int64
+	AdminId int64
+}
+
+type ReadRequest struct {
+	Types   string
+	UserId  int64
+	AdminId int64
+	MsgId   int64
+}
@@ -27,6 +27,23 @@ func (admin *Admin) UserTransfer(ctx context.Context, request *request.IdRequest
 	return nil
 }
 
+func (admin *Admin) UserAssigned(ctx context.Context, request *request.AssignRequest, response *response.NilResponse) error {
+	a := repositories.AdminRepo.FirstById(request.AdminId)
+	u := repositories.UserRepo.FirstById(request.UserId)
+	if a != nil && u != nil {
+		websocket.AdminManager.NoticeLocalUserAssigned(a, u, request.Unread)
+	}
+	return nil
+}
+
+func (admin *Admin) Note(ctx context.Context, request *request.IdRequest, response *response.NilResponse) error {
+	note := repositories.NoteRepo.FirstById(request.Id)
+	if note != nil {
+		websocket.AdminManager.BroadcastLocalNote(note)
+	}
+	return nil
+}
+
 func (admin *Admin) WaitingUser(ctx context.Context, request *request.GroupRequest, response *response.NilResponse) error {
 	websocket.AdminManager.BroadcastLocalWaitingUser(request.GroupId)
 	return nil
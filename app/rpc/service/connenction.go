@@ -69,6 +69,26 @@ func (connection *Connection) RepeatConnect(ctx context.Context, request *reques
 		if admin != nil {
 			websocket.AdminManager.NoticeLocalRepeatConnect(admin, request.NewUuid)
 		}
+	} else {
+		user := repositories.UserRepo.FirstById(request.Id)
+		if user != nil {
+			websocket.UserManager.NoticeLocalRepeatConnect(user, request.NewUuid)
+		}
 	}
 	return nil
 }
+
+func (connection *Connection) SseMessage(ctx context.Context, request *request.SseMessageRequest, response *response.NilResponse) error {
+	if request.Types == websocket.TypeAdmin {
+		admin := repositories.AdminRepo.FirstById(request.Id)
+		if admin == nil {
+			return errors.New("user not exit")
+		}
+		return websocket.AdminManager.ReceiveSseMessage(admin, request.Uuid, request.Body, true)
+	}
+	user := repositories.UserRepo.FirstById(request.Id)
+	if user == nil {
+		return errors.New("user not exit")
+	}
+	return websocket.UserManager.ReceiveSseMessage(user, request.Uuid, request.Body, true)
+}
@@ -13,7 +13,7 @@ type Message struct {
 }
 
 func (message *Message) Send(ctx context.Context, request *request.SendMessageRequest, response *response.NilResponse) error {
-	msg := repositories.MessageRepo.FirstById(request.Id)
+	msg := repositories.MessageRepo.FirstWithReply(request.Id)
 	var m websocket.MessageHandle
 	if msg != nil {
 		switch msg.Source {
@@ -26,3 +26,44 @@ func (message *Message) Send(ctx context.Context, request *request.SendMessageRe
 	}
 	return nil
 }
+
+func (message *Message) Typing(ctx context.Context, request *request.TypingRequest, response *response.NilResponse) error {
+	switch request.Types {
+	case websocket.TypeAdmin:
+		websocket.AdminManager.DeliveryTyping(request.Action, request.UserId, request.AdminId, true)
+	case websocket.TypeUser:
+		websocket.UserManager.DeliveryTyping(request.Action, request.UserId, request.AdminId, true)
+	}
+	return nil
+}
+
+func (message *Message) Read(ctx context.Context, request *request.ReadRequest, response *response.NilResponse) error {
+	switch request.Types {
+	case websocket.TypeAdmin:
+		websocket.AdminManager.DeliveryRead(request.UserId, request.AdminId, request.MsgId, true)
+	case websocket.TypeUser:
+		websocket.UserManager.DeliveryRead(request.UserId, request.AdminId, request.MsgId, true)
+	}
+	return nil
+}
+
+func (message *Message) Recall(ctx context.Context, request *request.SendMessageRequest, response *response.NilResponse) error {
+	msg := repositories.MessageRepo.FirstById(request.Id)
+	if msg != nil {
+		switch msg.Source {
+		case models.SourceUser:
+			websocket.AdminManager.DeliveryRecall(msg, true)
+		case models.SourceAdmin:
+			websocket.UserManager.DeliveryRecall(msg, true)
+		}
+	}
+	return nil
+}
+
+func (message *Message) Edit(ctx context.Context, request *request.SendMessageRequest, response *response.NilResponse) error {
+	msg := repositories.MessageRepo.FirstById(request.Id)
+	if msg != nil && msg.Source == models.SourceAdmin {
+		websocket.UserManager.DeliveryEdit(msg, true)
+	}
+	return nil
+}
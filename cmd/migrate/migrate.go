@@ -65,6 +65,214 @@ func getSettings() []*models.ChatSetting {
 		UpdatedAt: nil,
 		Type:      "select",
 	})
+	options3, _ := json.Marshal([]map[string]string{
+		{
+			"label": "1分钟",
+			"value": "1",
+		},
+		{
+			"label": "2分钟",
+			"value": "2",
+		},
+		{
+			"label": "5分钟",
+			"value": "5",
+		},
+	})
+	s = append(s, &models.ChatSetting{
+		Name:      models.MinuteToRecall,
+		Title:     "消息发送后多少分钟内可以撤回",
+		GroupId:   defaultGroupId,
+		Value:     "2",
+		Options:   string(options3),
+		CreatedAt: nil,
+		UpdatedAt: nil,
+		Type:      "select",
+	})
+	s = append(s, &models.ChatSetting{
+		Name:      models.IsSingleSession,
+		Title:     "是否只允许单端登录(新连接断开旧连接)",
+		GroupId:   defaultGroupId,
+		Value:     "0",
+		Options:   string(options1),
+		CreatedAt: nil,
+		UpdatedAt: nil,
+		Type:      "select",
+	})
+	options4, _ := json.Marshal([]map[string]string{
+		{
+			"label": "不自动切换",
+			"value": "0",
+		},
+		{
+			"label": "5分钟",
+			"value": "5",
+		},
+		{
+			"label": "10分钟",
+			"value": "10",
+		},
+		{
+			"label": "30分钟",
+			"value": "30",
+		},
+	})
+	s = append(s, &models.ChatSetting{
+		Name:      models.MinuteToAway,
+		Title:     "客服空闲多少分钟后自动切换为离开",
+		GroupId:   defaultGroupId,
+		Value:     "10",
+		Options:   string(options4),
+		CreatedAt: nil,
+		UpdatedAt: nil,
+		Type:      "select",
+	})
+	options5, _ := json.Marshal([]map[string]string{
+		{
+			"label": "轮流分配",
+			"value": "round-robin",
+		},
+		{
+			"label": "分配给接待用户最少的客服",
+			"value": "least-active",
+		},
+	})
+	s = append(s, &models.ChatSetting{
+		Name:      models.AssignStrategy,
+		Title:     "自动接入时的用户分配方式",
+		GroupId:   defaultGroupId,
+		Value:     "round-robin",
+		Options:   string(options5),
+		CreatedAt: nil,
+		UpdatedAt: nil,
+		Type:      "select",
+	})
+	options6, _ := json.Marshal([]map[string]string{
+		{
+			"label": "不限制",
+			"value": "0",
+		},
+		{
+			"label": "5人",
+			"value": "5",
+		},
+		{
+			"label": "10人",
+			"value": "10",
+		},
+		{
+			"label": "20人",
+			"value": "20",
+		},
+		{
+			"label": "50人",
+			"value": "50",
+		},
+	})
+	s = append(s, &models.ChatSetting{
+		Name:      models.MaxChats,
+		Title:     "客服默认的最大同时接待人数",
+		GroupId:   defaultGroupId,
+		Value:     "0",
+		Options:   string(options6),
+		CreatedAt: nil,
+		UpdatedAt: nil,
+		Type:      "select",
+	})
+	options7, _ := json.Marshal([]map[string]string{
+		{
+			"label": "不启用",
+			"value": "0",
+		},
+		{
+			"label": "2分钟",
+			"value": "2",
+		},
+		{
+			"label": "5分钟",
+			"value": "5",
+		},
+		{
+			"label": "10分钟",
+			"value": "10",
+		},
+		{
+			"label": "30分钟",
+			"value": "30",
+		},
+	})
+	s = append(s, &models.ChatSetting{
+		Name:      models.MinuteToPriority,
+		Title:     "VIP用户每级优先级排队时提前多少分钟",
+		GroupId:   defaultGroupId,
+		Value:     "5",
+		Options:   string(options7),
+		CreatedAt: nil,
+		UpdatedAt: nil,
+		Type:      "select",
+	})
+	options8, _ := json.Marshal([]map[string]string{
+		{
+			"label": "不限制",
+			"value": "0",
+		},
+		{
+			"label": "10分钟",
+			"value": "10",
+		},
+		{
+			"label": "30分钟",
+			"value": "30",
+		},
+		{
+			"label": "60分钟",
+			"value": "60",
+		},
+	})
+	s = append(s, &models.ChatSetting{
+		Name:      models.MinuteToQueueTimeout,
+		Title:     "用户排队超过多少分钟自动取消",
+		GroupId:   defaultGroupId,
+		Value:     "30",
+		Options:   string(options8),
+		CreatedAt: nil,
+		UpdatedAt: nil,
+		Type:      "select",
+	})
+	options9, _ := json.Marshal([]map[string]string{
+		{
+			"label": "1分钟",
+			"value": "1",
+		},
+		{
+			"label": "3分钟",
+			"value": "3",
+		},
+		{
+			"label": "5分钟",
+			"value": "5",
+		},
+	})
+	s = append(s, &models.ChatSetting{
+		Name:      models.MinuteToAbandon,
+		Title:     "排队的用户断开连接多少分钟后移出队列",
+		GroupId:   defaultGroupId,
+		Value:     "3",
+		Options:   string(options9),
+		CreatedAt: nil,
+		UpdatedAt: nil,
+		Type:      "select",
+	})
+	s = append(s, &models.ChatSetting{
+		Name:      models.IsSurvey,
+		Title:     "会话结束后是否发送满意度评价",
+		GroupId:   defaultGroupId,
+		Value:     "0",
+		Options:   string(options1),
+		CreatedAt: nil,
+		UpdatedAt: nil,
+		Type:      "select",
+	})
 	s = append(s, &models.ChatSetting{
 		Name:      models.SystemAvatar,
 		Title:     "系统头像",
@@ -85,6 +293,16 @@ func getSettings() []*models.ChatSetting {
 		CreatedAt: nil,
 		UpdatedAt: nil,
 	})
+	s = append(s, &models.ChatSetting{
+		Name:      models.Timezone,
+		Title:     "营业时间的时区",
+		GroupId:   defaultGroupId,
+		Value:     "Asia/Shanghai",
+		Options:   "",
+		Type:      "text",
+		CreatedAt: nil,
+		UpdatedAt: nil,
+	})
 	return s
 }
 
@@ -98,6 +316,8 @@ func NewMigrateCommand() *cobra.Command {
 			printErr(err)
 			err = databases.Db.AutoMigrate(&models.Message{})
 			printErr(err)
+			err = databases.Db.AutoMigrate(&models.MessageRevision{})
+			printErr(err)
 			err = databases.Db.AutoMigrate(&models.AutoMessage{})
 			printErr(err)
 			err = databases.Db.AutoMigrate(&models.AdminChatSetting{})
@@ -112,6 +332,18 @@ func NewMigrateCommand() *cobra.Command {
 			printErr(err)
 			err = databases.Db.AutoMigrate(&models.User{})
 			printErr(err)
+			err = databases.Db.AutoMigrate(&models.ChatQueue{})
+			printErr(err)
+			err = databases.Db.AutoMigrate(&models.BusinessHour{})
+			printErr(err)
+			err = databases.Db.AutoMigrate(&models.Holiday{})
+			printErr(err)
+			err = databases.Db.AutoMigrate(&models.Ticket{})
+			printErr(err)
+			err = databases.Db.AutoMigrate(&models.WrapUpCode{})
+			printErr(err)
+			err = databases.Db.AutoMigrate(&models.Note{})
+			printErr(err)
 			err = databases.Db.AutoMigrate(&models.ChatSetting{})
 			rules := []models.AutoRule{
 				{
@@ -130,6 +362,22 @@ func NewMigrateCommand() *cobra.Command {
 					IsSystem:  1,
 					GroupId:   defaultGroupId,
 				},
+				{
+					Name:      "当用户排队超时时(如不设置则回复默认的留言提示)",
+					MatchType: models.MatchTypeAll,
+					Match:     models.MatchQueueTimeout,
+					ReplyType: models.ReplyTypeMessage,
+					IsSystem:  1,
+					GroupId:   defaultGroupId,
+				},
+				{
+					Name:      "当用户在非营业时间转接人工时(如不设置则回复默认的非营业时间提示)",
+					MatchType: models.MatchTypeAll,
+					Match:     models.MatchOutOfHours,
+					ReplyType: models.ReplyTypeMessage,
+					IsSystem:  1,
+					GroupId:   defaultGroupId,
+				},
 			}
 			for _, rule := range rules {
 				var exist int64
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 	"ws/app/cron"
@@ -67,12 +68,24 @@ func NewServeCommand() *cobra.Command {
 					_ = rc.Close()
 				}()
 			}
-			defer func() {
-				websocket.AdminManager.Destroy()
-				websocket.UserManager.Destroy()
-			}()
 			sys.LogPid()
 			<-quit
+			// 排空websocket连接，等待客户端重连到其他server
+			drainCtx, drainCancel := context.WithTimeout(context.Background(), config.GetDrainTimeout())
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				websocket.AdminManager.Drain(drainCtx)
+				wg.Done()
+			}()
+			go func() {
+				websocket.UserManager.Drain(drainCtx)
+				wg.Done()
+			}()
+			wg.Wait()
+			drainCancel()
+			websocket.AdminManager.Destroy()
+			websocket.UserManager.Destroy()
 			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer func() {
 				cancel()
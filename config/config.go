@@ -2,6 +2,7 @@ package config
 
 import (
 	"log"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -40,3 +41,36 @@ func GetWorkDir() string {
 	}
 	return workDir
 }
+
+// GetDrainTimeout 停机时等待websocket连接断开的最长时间
+func GetDrainTimeout() time.Duration {
+	seconds := viper.GetInt("Websocket.DrainTimeout")
+	if seconds <= 0 {
+		seconds = 30
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetSendQueueSize 每个websocket连接的发送队列长度
+func GetSendQueueSize() int {
+	size := viper.GetInt("Websocket.SendQueueSize")
+	if size <= 0 {
+		size = 100
+	}
+	return size
+}
+
+// 发送队列满时的处理策略
+const (
+	OverflowDropOldest = "drop-oldest" // 队列满时丢弃最早的消息
+	OverflowDisconnect = "disconnect"  // 队列满时断开连接，由客户端重连后sync补发
+)
+
+// GetOverflowStrategy 发送队列满时的处理策略，默认丢弃最早的消息
+func GetOverflowStrategy() string {
+	strategy := viper.GetString("Websocket.Overflow")
+	if strategy != OverflowDisconnect {
+		strategy = OverflowDropOldest
+	}
+	return strategy
+}